@@ -0,0 +1,217 @@
+package scorekeeper
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+)
+
+// running is a Stat kept up to date as scores arrive.
+// If a score can't be stepped, err is kept and reported instead of the stat.
+type running struct {
+	st  stat.Stat
+	err error
+}
+
+// aggregates keeps running stats for each action of each scoreType,
+// so GetStats reports them instead of retrieving and recomputing every score.
+// Only the worker touches aggregates, so it needs no locking.
+type aggregates struct {
+	stats stat.Factory
+	// now is the clock for stats over a window of time
+	now func() time.Time
+	// tracked stat names by scoreType
+	tracked map[string][]string
+	// running stats by scoreType, action and stat name
+	m map[string]map[string]map[string]*running
+	// counts of scores by scoreType and action
+	counts map[string]map[string]int
+}
+
+func newAggregates(f stat.Factory, now func() time.Time) *aggregates {
+	return &aggregates{
+		stats:   f,
+		now:     now,
+		tracked: map[string][]string{},
+		m:       map[string]map[string]map[string]*running{},
+		counts:  map[string]map[string]int{},
+	}
+}
+
+// create the named stat, on the aggregates' clock if it needs one.
+func (a *aggregates) create(name string) (stat.Stat, error) {
+	st, err := stat.Create(a.stats, name)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := st.(stat.Timed); ok {
+		t.SetNow(a.now)
+	}
+
+	return st, nil
+}
+
+// untracked returns the stat names that have no running stats for scoreType yet.
+func (a *aggregates) untracked(scoreType string, names []string) []string {
+	have := map[string]bool{}
+	for _, name := range a.tracked[scoreType] {
+		have[name] = true
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !have[name] {
+			missing = append(missing, name)
+			have[name] = true
+		}
+	}
+
+	return missing
+}
+
+// track starts running stats with the given names for scoreType,
+// catching them up on every stored score.
+func (a *aggregates) track(scoreType string, names []string, scoreMap map[string][]score.Score) error {
+	if a.m[scoreType] == nil {
+		a.m[scoreType] = map[string]map[string]*running{}
+	}
+	if a.counts[scoreType] == nil {
+		a.counts[scoreType] = map[string]int{}
+	}
+
+	for action, all := range scoreMap {
+		// outliers are kept in the store, but left out of stats
+		scores := make([]score.Score, 0, len(all))
+		for _, s := range all {
+			if !score.IsOutlier(s) {
+				scores = append(scores, s)
+			}
+		}
+		if len(scores) == 0 {
+			continue
+		}
+
+		// scoreMap holds every score so far, whatever was counted before
+		a.counts[scoreType][action] = len(scores)
+
+		build := names
+		if a.m[scoreType][action] == nil {
+			// an action first seen here, like one stored by another writer to a shared store,
+			// needs the stats tracked before as well as the new ones
+			a.m[scoreType][action] = map[string]*running{}
+			build = append(append([]string{}, a.tracked[scoreType]...), names...)
+		}
+
+		for _, name := range build {
+			st, err := a.create(name)
+			if err != nil {
+				return err
+			}
+
+			r := &running{st: st}
+			for _, s := range scores {
+				if err := r.st.Step(s); err != nil {
+					r.err = err
+				}
+			}
+			a.m[scoreType][action][name] = r
+		}
+	}
+
+	// actions seen later need every tracked stat, so keep the names even if there are no scores yet
+	a.tracked[scoreType] = append(a.tracked[scoreType], names...)
+	return nil
+}
+
+// step a newly stored score into its action's running stats.
+func (a *aggregates) step(s score.Score) {
+	scoreType, action := s.Type(), s.Name()
+
+	names := a.tracked[scoreType]
+	if len(names) == 0 {
+		return
+	}
+
+	if a.m[scoreType][action] == nil {
+		a.m[scoreType][action] = map[string]*running{}
+	}
+	actionStats := a.m[scoreType][action]
+	a.counts[scoreType][action]++
+
+	for _, name := range names {
+		r, ok := actionStats[name]
+		if !ok {
+			// tracked names were created successfully once, so this can't fail
+			st, _ := a.create(name)
+			r = &running{st: st}
+			actionStats[name] = r
+		}
+
+		if err := r.st.Step(s); err != nil {
+			r.err = err
+		}
+	}
+}
+
+// report the named running stats for every action of scoreType, ordered by action name.
+func (a *aggregates) report(scoreType string, names []string) (StatsReport, error) {
+	actions := a.m[scoreType]
+	if len(actions) == 0 {
+		return StatsReport{}, stat.ErrNoData
+	}
+
+	r := StatsReport{
+		ScoreType: scoreType,
+		Actions:   make([]ActionStats, 0, len(actions)),
+	}
+
+	for action, actionStats := range actions {
+		as := ActionStats{
+			Action: action,
+			Count:  a.counts[scoreType][action],
+			Values: make(map[string]interface{}, len(names)),
+		}
+
+		for _, name := range names {
+			run, ok := actionStats[name]
+			if !ok {
+				return StatsReport{}, stat.ErrNoData
+			}
+			if run.err != nil {
+				return StatsReport{}, run.err
+			}
+
+			res, err := run.st.Report()
+			if errors.Is(err, stat.ErrNoData) && isWindow(run.st) {
+				// a window can be empty while the action has older scores
+				res, err = nil, nil
+			}
+			if err != nil {
+				return StatsReport{}, err
+			}
+
+			as.Values[name] = res
+		}
+
+		r.Actions = append(r.Actions, as)
+	}
+
+	sort.Slice(r.Actions, func(i, j int) bool {
+		return r.Actions[i].Action < r.Actions[j].Action
+	})
+
+	return r, nil
+}
+
+// isWindow reports whether st is over a window of the scores, which can be empty.
+func isWindow(st stat.Stat) bool {
+	switch st.(type) {
+	case *stat.LastN, *stat.Within:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,231 @@
+package scorekeeper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+// countingStore counts calls to Retrieve.
+type countingStore struct {
+	store.MemoryStore
+	retrieves int
+}
+
+func (c *countingStore) Retrieve(ctx context.Context, f score.ScoreFactory, scoreType string, q store.Query) (map[string][]score.Score, error) {
+	c.retrieves++
+	return c.MemoryStore.Retrieve(ctx, f, scoreType, q)
+}
+
+func TestAggregatesIncremental(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	// scores already in the store are picked up by Start
+	cs := &countingStore{}
+	if err := cs.MemoryStore.Store(context.Background(), &score.Trial{Action: "jump", Time: 300}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(cs, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if expected, got := 1, cs.retrieves; expected != got {
+		t.Errorf("Expected Start to retrieve %d times but got %d", expected, got)
+	}
+
+	for _, a := range []string{
+		`{"action":"jump", "time":100}`,
+		`{"action":"jump", "time":200}`,
+		`{"action":"hop", "time":50}`,
+	} {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.GetStats(scoreType, "avg", "count", "median"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first GetStats catches its stats up from the store, the rest keep them running
+	if expected, got := 2, cs.retrieves; expected != got {
+		t.Errorf("Expected GetStats to retrieve once, but retrieved %d times in total", got)
+	}
+
+	res, err := s.GetStats(scoreType, "avg", "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `[{"action":"jump","avg":200,"count":3},{"action":"hop","avg":50,"count":1}]`
+	if expected, got := e, res; !statsEquivalent(expected, got) {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+
+	// an unregistered percentile is caught up from the store once, then kept running
+	for i := 0; i < 2; i++ {
+		res, err = s.GetStats(scoreType, "p75")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected, got := 3, cs.retrieves; expected != got {
+		t.Errorf("Expected p75 to retrieve once, but retrieved %d times in total", got)
+	}
+
+	if err := s.AddAction(scoreType, `{"action":"hop", "time":150}`); err != nil {
+		t.Fatal(err)
+	}
+	res, err = s.GetStats(scoreType, "p75")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = `[{"action":"jump","p75":250},{"action":"hop","p75":125}]`
+	if expected, got := e, res; !statsEquivalent(expected, got) {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestAggregatesSharedStore(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	// two keepers writing to one store only see each other's scores when they catch up from it
+	st := store.NewMemoryStore()
+	a, err := New(st, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(st, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sk := range []*ScoreKeeper{a, b} {
+		if err := sk.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer sk.Stop()
+	}
+
+	if err := a.AddAction(scoreType, `{"action":"hop", "time":100}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.GetStats(scoreType, "avg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddAction(scoreType, `{"action":"skip", "time":50}`); err != nil {
+		t.Fatal(err)
+	}
+
+	// catching up p75 finds skip, which needs avg too
+	res, err := a.GetStats(scoreType, "p75")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `[{"action":"hop","p75":100},{"action":"skip","p75":50}]`
+	if expected, got := e, res; !statsEquivalent(expected, got) {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+
+	res, err = a.GetStats(scoreType, "avg", "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = `[{"action":"hop","avg":100,"count":1},{"action":"skip","avg":50,"count":1}]`
+	if expected, got := e, res; !statsEquivalent(expected, got) {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestAggregatesTrackAsked(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	s, err := New(nil, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// no stat keeps every value until one is asked for
+	if err := s.AddAction(scoreType, `{"action":"jump", "time":100}`); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 0, len(s.aggs.tracked[scoreType]); expected != got {
+		t.Errorf("Expected %d stats tracked before any were asked for but got %d", expected, got)
+	}
+
+	if _, err := s.GetStats(scoreType, "avg", "p99"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restart rebuilds the stats asked for, and only those
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if expected, got := []string{"avg", "p99"}, s.aggs.tracked[scoreType]; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v tracked after a restart but got %v", expected, got)
+	}
+	if expected, got := 2, len(s.aggs.m[scoreType]["jump"]); expected != got {
+		t.Errorf("Expected %d running stats for jump but got %d", expected, got)
+	}
+}
+
+// noData is a Stat that never has anything to report.
+type noData struct{}
+
+func (noData) Compute([]score.Score) (interface{}, error) { return nil, stat.ErrNoData }
+func (noData) Step(score.Score) error                     { return nil }
+func (noData) Report() (interface{}, error)               { return nil, stat.ErrNoData }
+
+func TestAggregatesNoData(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	s, err := New(nil, factory, WithStats(stat.Factory{
+		"broken": func() stat.Stat { return noData{} },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if err := s.AddAction(scoreType, `{"action":"jump", "time":100}`); err != nil {
+		t.Fatal(err)
+	}
+
+	// only a window can be empty for an action with scores, see TestGetStatsWindows
+	if _, err := s.GetStats(scoreType, "broken"); !errors.Is(err, stat.ErrNoData) {
+		t.Errorf("Expected error to be '%v' but got '%v'", stat.ErrNoData, err)
+	}
+}
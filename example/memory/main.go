@@ -14,7 +14,7 @@ var (
 )
 
 func main() {
-	st := &store.MemoryStore{}
+	st := store.NewMemoryStore()
 	factory := score.ScoreFactory{
 		"trial": func() score.Score { return &score.Trial{} },
 	}
@@ -24,7 +24,9 @@ func main() {
 		panic(fmt.Errorf("error creating scoreKeeper: %v", err))
 	}
 
-	scoreKeeper.Start()
+	if err := scoreKeeper.Start(); err != nil {
+		panic(fmt.Errorf("error starting scoreKeeper: %v", err))
+	}
 	defer scoreKeeper.Stop()
 
 	actions := []string{
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/bdharris08/scorekeeper"
 	"github.com/bdharris08/scorekeeper/score"
@@ -19,9 +20,6 @@ var (
 	dsn        = flag.String("dsn", exampleDSN, "dsn for postgres database")
 )
 
-//TODO use cohorts to fix averages, currently just grabbing all scores for each run
-// memorystore handled that by being destroyed every run
-
 func getDSN(dsnFlag *string) (string, error) {
 	flag.Parse()
 	if *dsn != exampleDSN {
@@ -50,19 +48,28 @@ func main() {
 	}
 	defer db.Close()
 
-	st, _ := store.NewSQLStore(db)
 	factory := score.ScoreFactory{
 		"trial": func() score.Score { return &score.Trial{} },
 	}
+	st, err := store.NewSQLStore(db, store.WithScoreFactory(factory), store.WithAutoMigrate())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to migrate database: %v\n", err)
+		os.Exit(1)
+	}
 
 	scoreKeeper, err := scorekeeper.New(st, factory)
 	if err != nil {
 		panic(fmt.Errorf("error creating scoreKeeper: %v", err))
 	}
 
-	scoreKeeper.Start()
+	if err := scoreKeeper.Start(); err != nil {
+		panic(fmt.Errorf("error starting scoreKeeper: %v", err))
+	}
 	defer scoreKeeper.Stop()
 
+	// keep each run's scores in their own cohort, so old runs don't skew this run's averages
+	run := time.Now().UTC().Format(time.RFC3339Nano)
+
 	actions := []string{
 		`{"action":"hop", "time":100}`,
 		`{"action":"skip", "time":100}`,
@@ -83,11 +90,11 @@ func main() {
 
 	// A simple example
 	for _, a := range actions {
-		if err := scoreKeeper.AddAction("trial", a); err != nil {
+		if err := scoreKeeper.AddCohortAction("trial", run, a); err != nil {
 			fmt.Println(err)
 		}
 	}
-	result, err := scoreKeeper.GetStats("trial")
+	result, err := scoreKeeper.GetCohortStats("trial", []string{run})
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -102,11 +109,11 @@ func main() {
 		go func() {
 			defer wg.Done()
 			for _, a := range actions {
-				if err := scoreKeeper.AddAction("trial", a); err != nil {
+				if err := scoreKeeper.AddCohortAction("trial", run, a); err != nil {
 					fmt.Println(err)
 				}
 			}
-			result, err := scoreKeeper.GetStats("trial")
+			result, err := scoreKeeper.GetCohortStats("trial", []string{run})
 			if err != nil {
 				fmt.Println(err)
 			}
@@ -119,7 +126,7 @@ func main() {
 
 	wg.Wait()
 
-	result, err = scoreKeeper.GetStats("trial")
+	result, err = scoreKeeper.GetCohortStats("trial", []string{run})
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -3,6 +3,7 @@ module github.com/bdharris08/scorekeeper
 go 1.15
 
 require (
-	github.com/DATA-DOG/go-sqlmock v1.5.0 // indirect
-	github.com/jackc/pgx/v4 v4.15.0 // indirect
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/jackc/pgx/v4 v4.15.0
+	github.com/mattn/go-sqlite3 v1.14.16
 )
@@ -0,0 +1,295 @@
+package scorekeeper
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+// Leaderboard ranks the players of one action.
+type Leaderboard struct {
+	ScoreType string
+	Action    string
+	Metric    store.Metric
+	// Standings from first place down. Players with the same value share a rank.
+	Standings []store.Standing
+}
+
+var ErrUnranked = errors.New("player not ranked")
+
+// player is what a board knows of one player of one action.
+type player struct {
+	name  string
+	count int
+	sum   float64
+	best  float64
+}
+
+// value the player is ranked by for metric m.
+func (p *player) value(m store.Metric) float64 {
+	switch m {
+	case store.Average:
+		return p.sum / float64(p.count)
+	case store.Total:
+		return p.sum
+	default:
+		return p.best
+	}
+}
+
+// metrics boards keep players sorted by.
+var metrics = []store.Metric{store.Best, store.Average, store.Total}
+
+// board ranks the players of one action, keeping them sorted by each metric as scores arrive.
+type board struct {
+	// higher values are better, see score.HigherIsBetter
+	higher  bool
+	players map[string]*player
+	// sorted players by metric, first place first
+	sorted map[store.Metric][]*player
+}
+
+func newBoard(higher bool) *board {
+	return &board{
+		higher:  higher,
+		players: map[string]*player{},
+		sorted:  map[store.Metric][]*player{},
+	}
+}
+
+// better reports whether value x ranks ahead of value y for metric m.
+func (b *board) better(m store.Metric, x, y float64) bool {
+	if (store.RankQuery{Metric: m, Higher: b.higher}).Descending() {
+		return x > y
+	}
+	return x < y
+}
+
+// before orders players for metric m: better values first, then by name.
+func (b *board) before(m store.Metric, p, q *player) bool {
+	if pv, qv := p.value(m), q.value(m); pv != qv {
+		return b.better(m, pv, qv)
+	}
+	return p.name < q.name
+}
+
+// search returns the index p has, or belongs at, in the players sorted by metric m.
+func (b *board) search(m store.Metric, p *player) int {
+	sorted := b.sorted[m]
+	return sort.Search(len(sorted), func(i int) bool {
+		return !b.before(m, sorted[i], p)
+	})
+}
+
+// step a player's score into the board, moving the player to their new place.
+func (b *board) step(name string, v float64) {
+	p, ok := b.players[name]
+	if ok {
+		for _, m := range metrics {
+			i := b.search(m, p)
+			b.sorted[m] = append(b.sorted[m][:i], b.sorted[m][i+1:]...)
+		}
+	} else {
+		p = &player{name: name, best: v}
+		b.players[name] = p
+	}
+
+	p.count++
+	p.sum += v
+	if b.better(store.Best, v, p.best) {
+		p.best = v
+	}
+
+	for _, m := range metrics {
+		i := b.search(m, p)
+		sorted := append(b.sorted[m], nil)
+		copy(sorted[i+1:], sorted[i:])
+		sorted[i] = p
+		b.sorted[m] = sorted
+	}
+}
+
+// standing of the player at index i of the players sorted by metric m.
+// Players tied with the ones before them share their rank.
+func (b *board) standing(m store.Metric, i int) store.Standing {
+	sorted := b.sorted[m]
+	p := sorted[i]
+	v := p.value(m)
+
+	first := sort.Search(i, func(j int) bool {
+		return !b.better(m, sorted[j].value(m), v)
+	})
+
+	return store.Standing{Player: p.name, Rank: first + 1, Value: v, Count: p.count}
+}
+
+// top returns the first n standings by metric m, or every standing if n isn't positive.
+func (b *board) top(m store.Metric, n int) []store.Standing {
+	sorted := b.sorted[m]
+	if n <= 0 || n > len(sorted) {
+		n = len(sorted)
+	}
+
+	standings := make([]store.Standing, 0, n)
+	for i := 0; i < n; i++ {
+		standings = append(standings, b.standing(m, i))
+	}
+
+	return standings
+}
+
+// rank returns the standing of the named player by metric m, if they have one.
+func (b *board) rank(m store.Metric, name string) (store.Standing, bool) {
+	p, ok := b.players[name]
+	if !ok {
+		return store.Standing{}, false
+	}
+
+	return b.standing(m, b.search(m, p)), true
+}
+
+// ranks keeps a board for each action of each scoreType, for leaderboards.
+// Only the worker touches ranks, so it needs no locking.
+type ranks struct {
+	// boards by scoreType and action
+	m map[string]map[string]*board
+}
+
+func newRanks() *ranks {
+	return &ranks{m: map[string]map[string]*board{}}
+}
+
+// step a newly stored score into its action's board, if it has a player.
+func (r *ranks) step(s score.Score) {
+	name := score.PlayerOf(s)
+	v, ok := s.Value().(float64)
+	if name == "" || !ok {
+		return
+	}
+
+	scoreType, action := s.Type(), s.Name()
+	if r.m[scoreType] == nil {
+		r.m[scoreType] = map[string]*board{}
+	}
+	b := r.m[scoreType][action]
+	if b == nil {
+		b = newBoard(score.HigherIsBetter(s))
+		r.m[scoreType][action] = b
+	}
+
+	b.step(name, v)
+}
+
+// rank answers q from the boards of scoreType.
+func (r *ranks) rank(scoreType string, q store.RankQuery) []store.Standing {
+	b := r.m[scoreType][q.Action]
+	if b == nil {
+		return nil
+	}
+
+	if q.Player != "" {
+		st, ok := b.rank(q.Metric, q.Player)
+		if !ok {
+			return nil
+		}
+		return []store.Standing{st}
+	}
+
+	return b.top(q.Metric, q.Limit)
+}
+
+// Leaderboard ranks the players of an action by metric m, returning the top n, or every player if n isn't positive.
+// Only scores with a "player" are ranked. Best and Average rank lower values first,
+// unless the scoreType is score.Higher; Total always ranks the highest first.
+func (sk *ScoreKeeper) Leaderboard(scoreType, action string, m store.Metric, n int) (Leaderboard, error) {
+	return sk.LeaderboardContext(context.Background(), scoreType, action, m, n)
+}
+
+// LeaderboardContext is Leaderboard with a context.
+// It gives up and returns ctx.Err() if the context is done before the leaderboard is ready.
+func (sk *ScoreKeeper) LeaderboardContext(ctx context.Context, scoreType, action string, m store.Metric, n int) (Leaderboard, error) {
+	standings, err := sk.rank(ctx, scoreType, store.RankQuery{Action: action, Metric: m, Limit: n})
+	if err != nil {
+		return Leaderboard{}, err
+	}
+
+	return Leaderboard{ScoreType: scoreType, Action: action, Metric: m, Standings: standings}, nil
+}
+
+// PlayerRank returns the standing of a player on the leaderboard of an action by metric m.
+// It returns ErrUnranked if the player has no scores for the action.
+func (sk *ScoreKeeper) PlayerRank(scoreType, action, player string, m store.Metric) (store.Standing, error) {
+	return sk.PlayerRankContext(context.Background(), scoreType, action, player, m)
+}
+
+// PlayerRankContext is PlayerRank with a context.
+// It gives up and returns ctx.Err() if the context is done before the rank is ready.
+func (sk *ScoreKeeper) PlayerRankContext(ctx context.Context, scoreType, action, player string, m store.Metric) (store.Standing, error) {
+	if player == "" {
+		return store.Standing{}, ErrUnranked
+	}
+
+	standings, err := sk.rank(ctx, scoreType, store.RankQuery{Action: action, Metric: m, Player: player})
+	if err != nil {
+		return store.Standing{}, err
+	}
+	if len(standings) == 0 {
+		return store.Standing{}, ErrUnranked
+	}
+
+	return standings[0], nil
+}
+
+// rank asks the worker to answer q.
+func (sk *ScoreKeeper) rank(ctx context.Context, scoreType string, q store.RankQuery) ([]store.Standing, error) {
+	if sk.s == nil {
+		return nil, ErrNoKeeper
+	}
+	w, err := sk.channels()
+	if err != nil {
+		return nil, err
+	}
+	if valid := ValidScoreType(sk, scoreType); !valid {
+		return nil, score.ErrBadScoreType
+	}
+
+	s, err := score.Create(sk.f, scoreType)
+	if err != nil {
+		return nil, err
+	}
+	q.Higher = score.HigherIsBetter(s)
+
+	rankCh := make(chan rankResult, 1)
+	select {
+	case w.ranks <- rankEnvelope{
+		ctx:       ctx,
+		scoreType: scoreType,
+		query:     q,
+		r:         rankCh,
+	}:
+	case <-w.quit:
+		return nil, ErrNotRunning
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case res := <-rankCh:
+		return res.standings, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// standings ranks players for the worker.
+// A store.Ranker ranks them itself, seeing scores from every ScoreKeeper sharing the store.
+func (sk *ScoreKeeper) standings(ctx context.Context, scoreType string, q store.RankQuery) ([]store.Standing, error) {
+	if r, ok := sk.s.(store.Ranker); ok {
+		return r.Rank(ctx, sk.f, scoreType, q)
+	}
+
+	return sk.ranks.rank(scoreType, q), nil
+}
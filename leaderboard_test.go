@@ -0,0 +1,271 @@
+package scorekeeper
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/store"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// points is a Trial where a higher value is better.
+type points struct {
+	score.Trial
+}
+
+func (p *points) Type() string {
+	return "points"
+}
+
+func (p *points) HigherIsBetter() bool {
+	return true
+}
+
+func TestLeaderboard(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial":  func() score.Score { return &score.Trial{} },
+		"points": func() score.Score { return &points{} },
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scores.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sqlite, err := store.NewSQLStore(db, store.WithDialect(store.SQLite), store.WithScoreFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ranked in memory, and by the database
+	for _, st := range []store.ScoreStore{&store.MemoryStore{}, sqlite} {
+		s, err := New(st, factory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, a := range []string{
+			`{"action":"hop", "time":10, "player":"ann"}`,
+			`{"action":"hop", "time":30, "player":"ann"}`,
+			`{"action":"hop", "time":20, "player":"bob"}`,
+			`{"action":"hop", "time":60, "player":"cat"}`,
+			`{"action":"hop", "time":10, "player":"cat"}`,
+			`{"action":"hop", "time":50, "player":"cat"}`,
+			`{"action":"hop", "time":1}`,
+			`{"action":"jump", "time":5, "player":"dan"}`,
+		} {
+			if err := s.AddAction("trial", a); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AddAction("points", a); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		type testCase struct {
+			name      string
+			scoreType string
+			metric    store.Metric
+			n         int
+			standings []store.Standing
+		}
+
+		testCases := []testCase{
+			{
+				name:      "best",
+				scoreType: "trial",
+				metric:    store.Best,
+				standings: []store.Standing{
+					{Player: "ann", Rank: 1, Value: 10, Count: 2},
+					{Player: "cat", Rank: 1, Value: 10, Count: 3},
+					{Player: "bob", Rank: 3, Value: 20, Count: 1},
+				},
+			},
+			{
+				name:      "average",
+				scoreType: "trial",
+				metric:    store.Average,
+				standings: []store.Standing{
+					{Player: "ann", Rank: 1, Value: 20, Count: 2},
+					{Player: "bob", Rank: 1, Value: 20, Count: 1},
+					{Player: "cat", Rank: 3, Value: 40, Count: 3},
+				},
+			},
+			{
+				name:      "total",
+				scoreType: "trial",
+				metric:    store.Total,
+				standings: []store.Standing{
+					{Player: "cat", Rank: 1, Value: 120, Count: 3},
+					{Player: "ann", Rank: 2, Value: 40, Count: 2},
+					{Player: "bob", Rank: 3, Value: 20, Count: 1},
+				},
+			},
+			{
+				name:      "top",
+				scoreType: "trial",
+				metric:    store.Best,
+				n:         2,
+				standings: []store.Standing{
+					{Player: "ann", Rank: 1, Value: 10, Count: 2},
+					{Player: "cat", Rank: 1, Value: 10, Count: 3},
+				},
+			},
+			{
+				name:      "higher is better",
+				scoreType: "points",
+				metric:    store.Best,
+				standings: []store.Standing{
+					{Player: "cat", Rank: 1, Value: 60, Count: 3},
+					{Player: "ann", Rank: 2, Value: 30, Count: 2},
+					{Player: "bob", Rank: 3, Value: 20, Count: 1},
+				},
+			},
+			{
+				name:      "higher average is better",
+				scoreType: "points",
+				metric:    store.Average,
+				standings: []store.Standing{
+					{Player: "cat", Rank: 1, Value: 40, Count: 3},
+					{Player: "ann", Rank: 2, Value: 20, Count: 2},
+					{Player: "bob", Rank: 2, Value: 20, Count: 1},
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			lb, err := s.Leaderboard(tc.scoreType, "hop", tc.metric, tc.n)
+			if err != nil {
+				t.Errorf("[%T %s] Expected no error but got '%v'", st, tc.name, err)
+				continue
+			}
+			if expected, got := len(tc.standings), len(lb.Standings); expected != got {
+				t.Errorf("[%T %s] Expected %d standings but got %v", st, tc.name, expected, lb.Standings)
+				continue
+			}
+			for i := range tc.standings {
+				if expected, got := tc.standings[i], lb.Standings[i]; expected != got {
+					t.Errorf("[%T %s] Expected standing %d to be %+v but got %+v", st, tc.name, i, expected, got)
+				}
+			}
+		}
+
+		rank, err := s.PlayerRank("trial", "hop", "bob", store.Best)
+		if err != nil {
+			t.Errorf("[%T] Expected no error but got '%v'", st, err)
+		}
+		if expected, got := (store.Standing{Player: "bob", Rank: 3, Value: 20, Count: 1}), rank; expected != got {
+			t.Errorf("[%T] Expected %+v but got %+v", st, expected, got)
+		}
+
+		if _, err := s.PlayerRank("trial", "hop", "dan", store.Best); err != ErrUnranked {
+			t.Errorf("[%T] Expected error to be '%v' but got '%v'", st, ErrUnranked, err)
+		}
+
+		lb, err := s.Leaderboard("trial", "sit", store.Best, 10)
+		if err != nil {
+			t.Errorf("[%T] Expected no error but got '%v'", st, err)
+		}
+		if len(lb.Standings) != 0 {
+			t.Errorf("[%T] Expected no standings but got %v", st, lb.Standings)
+		}
+
+		s.Stop()
+
+		if _, err := s.Leaderboard("trial", "hop", store.Best, 10); err != ErrNotRunning {
+			t.Errorf("[%T] Expected error to be '%v' but got '%v'", st, ErrNotRunning, err)
+		}
+
+		// the ranks are rebuilt from the store
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		rank, err = s.PlayerRank("trial", "hop", "cat", store.Total)
+		if err != nil {
+			t.Errorf("[%T] Expected no error but got '%v'", st, err)
+		}
+		if expected, got := (store.Standing{Player: "cat", Rank: 1, Value: 120, Count: 3}), rank; expected != got {
+			t.Errorf("[%T] Expected %+v after restarting but got %+v", st, expected, got)
+		}
+		s.Stop()
+	}
+}
+
+// TestBoardIncremental checks the ranks kept as scores arrive against ranking every player from scratch.
+func TestBoardIncremental(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, higher := range []bool{false, true} {
+		b := newBoard(higher)
+		players := map[string][]float64{}
+
+		for i := 0; i < 500; i++ {
+			name := fmt.Sprintf("p%d", r.Intn(20))
+			// few distinct values, so there are plenty of ties
+			v := float64(r.Intn(10))
+			b.step(name, v)
+			players[name] = append(players[name], v)
+		}
+
+		for _, m := range metrics {
+			q := store.RankQuery{Metric: m, Higher: higher}
+
+			type naive struct {
+				name  string
+				value float64
+			}
+			var all []naive
+			for name, values := range players {
+				sum, best := 0.0, values[0]
+				for _, v := range values {
+					sum += v
+					if (q.Descending() && v > best) || (!q.Descending() && v < best) {
+						best = v
+					}
+				}
+				value := best
+				switch m {
+				case store.Average:
+					value = sum / float64(len(values))
+				case store.Total:
+					value = sum
+				}
+				all = append(all, naive{name: name, value: value})
+			}
+
+			sort.Slice(all, func(i, j int) bool {
+				if all[i].value != all[j].value {
+					return (all[i].value < all[j].value) != q.Descending()
+				}
+				return all[i].name < all[j].name
+			})
+
+			got := b.top(m, 0)
+			if expected, got := len(all), len(got); expected != got {
+				t.Fatalf("[%v %v] Expected %d standings but got %d", m, higher, expected, got)
+			}
+			for i, e := range all {
+				rank := i + 1
+				for rank > 1 && all[rank-2].value == e.value {
+					rank--
+				}
+				if got[i].Player != e.name || got[i].Value != e.value || got[i].Rank != rank {
+					t.Errorf("[%v %v] Expected standing %d to be %s %v #%d but got %+v", m, higher, i, e.name, e.value, rank, got[i])
+				}
+
+				st, ok := b.rank(m, e.name)
+				if !ok || st != got[i] {
+					t.Errorf("[%v %v] Expected rank of %s to be %+v but got %+v", m, higher, e.name, got[i], st)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package scorekeeper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// OutlierPolicy decides what the worker does with a score whose value is an outlier for its action,
+// like a time of 9999999 from a stuck timer, before it reaches the ScoreStore.
+// Set one per scoreType with WithOutlierPolicy.
+type OutlierPolicy struct {
+	// Detect finds the values that are outliers.
+	Detect OutlierDetector
+	// Action is taken on an outlier. The default is RejectOutliers.
+	Action OutlierAction
+	// Window is how many of each action's latest values Detect judges by. The default is 1000.
+	Window int
+}
+
+// OutlierAction is what is done with an outlier.
+type OutlierAction int
+
+const (
+	// RejectOutliers returns an *OutlierError for an outlier, and doesn't keep it.
+	RejectOutliers OutlierAction = iota
+	// FlagOutliers keeps an outlier with its score.Meta.Outlier set, leaving it out of stats, leaderboards and records.
+	// The scoreType must embed score.Meta.
+	FlagOutliers
+	// ClampOutliers keeps an outlier with its value moved to the nearest value that isn't one.
+	ClampOutliers
+)
+
+// defaultOutlierWindow is how many values of each action an OutlierPolicy judges by, unless it says otherwise.
+const defaultOutlierWindow = 1000
+
+// OutlierDetector finds the range of values of an action that aren't outliers,
+// given the latest values kept for it, oldest first.
+// ok is false if it can't tell, like before it has seen enough values, and then every value is kept.
+// ZScore, IQR and MAD judge by how spread out the latest values are: if they are all the same,
+// every other value is an outlier unless their MinSpread allows some.
+type OutlierDetector interface {
+	Bounds(latest []float64) (lo, hi float64, ok bool)
+}
+
+// defaultMinSamples is how many values the detectors that judge by them need, unless they say otherwise.
+const defaultMinSamples = 10
+
+// ZScore detects values more than Max standard deviations from the mean.
+// One large outlier inflates the standard deviation, so IQR and MAD are more robust.
+type ZScore struct {
+	// Max standard deviations from the mean. The default is 3.
+	Max float64
+	// MinSamples needed to judge. The default is 10.
+	MinSamples int
+	// MinSpread is the least standard deviation judged by. The default is 0.
+	MinSpread float64
+}
+
+// Bounds are the mean, plus or minus Max standard deviations.
+func (z ZScore) Bounds(latest []float64) (float64, float64, bool) {
+	if len(latest) < orDefault(z.MinSamples, defaultMinSamples) {
+		return 0, 0, false
+	}
+
+	var n, mean, m2 float64
+	for _, v := range latest {
+		n++
+		delta := v - mean
+		mean += delta / n
+		m2 += delta * (v - mean)
+	}
+
+	sd := math.Max(math.Sqrt(m2/n), z.MinSpread)
+
+	max := z.Max
+	if max == 0 {
+		max = 3
+	}
+	return mean - max*sd, mean + max*sd, true
+}
+
+// IQR detects values more than K interquartile ranges below the first quartile or above the third,
+// known as Tukey's fences.
+type IQR struct {
+	// K interquartile ranges beyond the quartiles. The default is 1.5.
+	K float64
+	// MinSamples needed to judge. The default is 10.
+	MinSamples int
+	// MinSpread is the least interquartile range judged by. The default is 0.
+	MinSpread float64
+}
+
+// Bounds are the quartiles, widened by K interquartile ranges.
+func (r IQR) Bounds(latest []float64) (float64, float64, bool) {
+	if len(latest) < orDefault(r.MinSamples, defaultMinSamples) {
+		return 0, 0, false
+	}
+
+	sorted := sortedCopy(latest)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := math.Max(q3-q1, r.MinSpread)
+
+	k := r.K
+	if k == 0 {
+		k = 1.5
+	}
+	return q1 - k*iqr, q3 + k*iqr, true
+}
+
+// MAD detects values more than Max median absolute deviations from the median.
+// The deviation is scaled by 1.4826 to be comparable to a standard deviation for normally distributed values.
+type MAD struct {
+	// Max scaled median absolute deviations from the median. The default is 3.5.
+	Max float64
+	// MinSamples needed to judge. The default is 10.
+	MinSamples int
+	// MinSpread is the least scaled median absolute deviation judged by. The default is 0.
+	MinSpread float64
+}
+
+// Bounds are the median, plus or minus Max scaled median absolute deviations.
+func (d MAD) Bounds(latest []float64) (float64, float64, bool) {
+	if len(latest) < orDefault(d.MinSamples, defaultMinSamples) {
+		return 0, 0, false
+	}
+
+	sorted := sortedCopy(latest)
+	median := quantile(sorted, 0.5)
+
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+
+	mad := math.Max(1.4826*quantile(deviations, 0.5), d.MinSpread)
+
+	max := d.Max
+	if max == 0 {
+		max = 3.5
+	}
+	return median - max*mad, median + max*mad, true
+}
+
+// Range detects values below Min or above Max, whatever came before them.
+// Use math.Inf(-1) or math.Inf(1) to leave one end open.
+type Range struct {
+	Min, Max float64
+}
+
+// Bounds are Min and Max.
+func (r Range) Bounds([]float64) (float64, float64, bool) {
+	return r.Min, r.Max, true
+}
+
+// orDefault returns n, or def if n isn't positive.
+func orDefault(n, def int) int {
+	if n < 1 {
+		return def
+	}
+	return n
+}
+
+// sortedCopy of vs, in ascending order.
+func sortedCopy(vs []float64) []float64 {
+	sorted := append([]float64(nil), vs...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// quantile q of sorted, interpolating linearly between the closest ranks like stat.Percentile.
+func quantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lo, hi := math.Floor(rank), math.Ceil(rank)
+	low, high := sorted[int(lo)], sorted[int(hi)]
+
+	return low + (rank-lo)*(high-low)
+}
+
+var ErrOutlier = errors.New("outlier")
+var ErrBadOutlierPolicy = errors.New("invalid outlier policy")
+
+// OutlierError is returned for a score rejected by RejectOutliers. It matches ErrOutlier with errors.Is.
+type OutlierError struct {
+	ScoreType string
+	Action    string
+	Value     float64
+	// Min and Max bound the values that weren't outliers.
+	Min, Max float64
+}
+
+func (e *OutlierError) Error() string {
+	return fmt.Sprintf("outlier: %s %s of %v is outside [%v, %v]", e.ScoreType, e.Action, e.Value, e.Min, e.Max)
+}
+
+// Is makes an OutlierError match ErrOutlier.
+func (e *OutlierError) Is(target error) bool {
+	return target == ErrOutlier
+}
+
+// WithOutlierPolicy screens the scores of scoreType for outliers with policy p.
+// New checks the policy, and that scoreType is registered.
+func WithOutlierPolicy(scoreType string, p OutlierPolicy) Option {
+	return func(sk *ScoreKeeper) {
+		if sk.outlierPolicies == nil {
+			sk.outlierPolicies = map[string]OutlierPolicy{}
+		}
+		sk.outlierPolicies[scoreType] = p
+	}
+}
+
+// checkOutlierPolicies are usable with the scoreTypes of factory f.
+func checkOutlierPolicies(policies map[string]OutlierPolicy, f score.ScoreFactory) error {
+	for scoreType, p := range policies {
+		s, err := score.Create(f, scoreType)
+		if err != nil {
+			return fmt.Errorf("%w for %s: %v", ErrBadOutlierPolicy, scoreType, err)
+		}
+		if p.Detect == nil {
+			return fmt.Errorf("%w for %s: no detector", ErrBadOutlierPolicy, scoreType)
+		}
+		if p.Action < RejectOutliers || p.Action > ClampOutliers {
+			return fmt.Errorf("%w for %s: unknown action %d", ErrBadOutlierPolicy, scoreType, p.Action)
+		}
+		if p.Action == FlagOutliers && score.MetadataOf(s) == nil {
+			return fmt.Errorf("%w for %s: %v", ErrBadOutlierPolicy, scoreType, score.ErrNoMeta)
+		}
+	}
+
+	return nil
+}
+
+// latest is a ring of an action's latest values, oldest at next once full.
+type latest struct {
+	vs   []float64
+	next int
+}
+
+// values in the ring, oldest first.
+func (l *latest) values() []float64 {
+	vs := make([]float64, 0, len(l.vs))
+	vs = append(vs, l.vs[l.next:]...)
+	return append(vs, l.vs[:l.next]...)
+}
+
+// push v into a ring of up to n values, pushing out the oldest once it is full.
+func (l *latest) push(v float64, n int) {
+	if len(l.vs) < n {
+		l.vs = append(l.vs, v)
+		return
+	}
+
+	l.vs[l.next] = v
+	l.next = (l.next + 1) % n
+}
+
+// outliers screens scores by the policy of their scoreType,
+// judging them by the latest values kept for their action.
+// Only the worker touches outliers, so it needs no locking.
+type outliers struct {
+	policies map[string]OutlierPolicy
+	// latest values by scoreType and action
+	latest map[string]map[string]*latest
+}
+
+func newOutliers(policies map[string]OutlierPolicy) *outliers {
+	return &outliers{
+		policies: policies,
+		latest:   map[string]map[string]*latest{},
+	}
+}
+
+// screen a score before it is stored: an outlier is rejected with an *OutlierError,
+// or flagged or clamped in place, by the policy of its scoreType.
+func (o *outliers) screen(s score.Score) error {
+	p, ok := o.policies[s.Type()]
+	if !ok {
+		return nil
+	}
+	v, ok := s.Value().(float64)
+	if !ok {
+		return nil
+	}
+
+	var kept []float64
+	if l := o.latest[s.Type()][s.Name()]; l != nil {
+		kept = l.values()
+	}
+	lo, hi, ok := p.Detect.Bounds(kept)
+	if !ok || (v >= lo && v <= hi) {
+		return nil
+	}
+
+	switch p.Action {
+	case FlagOutliers:
+		score.MetadataOf(s).Outlier = true
+		return nil
+	case ClampOutliers:
+		return s.Set(s.Name(), math.Max(lo, math.Min(hi, v)))
+	default:
+		return &OutlierError{ScoreType: s.Type(), Action: s.Name(), Value: v, Min: lo, Max: hi}
+	}
+}
+
+// step a stored score, that isn't an outlier, into the latest values of its action.
+func (o *outliers) step(s score.Score) {
+	p, ok := o.policies[s.Type()]
+	if !ok {
+		return
+	}
+	v, ok := s.Value().(float64)
+	if !ok {
+		return
+	}
+
+	if o.latest[s.Type()] == nil {
+		o.latest[s.Type()] = map[string]*latest{}
+	}
+	l := o.latest[s.Type()][s.Name()]
+	if l == nil {
+		l = &latest{}
+		o.latest[s.Type()][s.Name()] = l
+	}
+	l.push(v, orDefault(p.Window, defaultOutlierWindow))
+}
+
+// screen the scores of an envelope for those too far ahead of now and outliers, returning those to store,
+// and the error for each one rejected by index, or nil if none were.
+func (sk *ScoreKeeper) screen(ss []score.Score, now time.Time) ([]score.Score, []error) {
+	latest := now.Add(sk.maxSkew)
+
+	var rejected []error
+	kept := make([]score.Score, 0, len(ss))
+	for i, s := range ss {
+		err := checkAt(s, latest)
+		if err == nil {
+			err = sk.outliers.screen(s)
+		}
+		if err != nil {
+			if rejected == nil {
+				rejected = make([]error, len(ss))
+			}
+			rejected[i] = err
+			continue
+		}
+		kept = append(kept, s)
+	}
+
+	return kept, rejected
+}
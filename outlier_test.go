@@ -0,0 +1,348 @@
+package scorekeeper
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+func TestOutlierDetectors(t *testing.T) {
+	// ten values around 100
+	latest := []float64{96, 98, 99, 100, 100, 100, 101, 102, 102, 102}
+	// ten values with no spread, like a stuck timer
+	same := []float64{5, 5, 5, 5, 5, 5, 5, 5, 5, 5}
+
+	type testCase struct {
+		name   string
+		detect OutlierDetector
+		latest []float64
+		lo, hi float64
+		ok     bool
+	}
+
+	testCases := []testCase{
+		{name: "zscore", detect: ZScore{}, latest: latest, lo: 100 - 3*math.Sqrt(3.4), hi: 100 + 3*math.Sqrt(3.4), ok: true},
+		{name: "zscore max", detect: ZScore{Max: 1}, latest: latest, lo: 100 - math.Sqrt(3.4), hi: 100 + math.Sqrt(3.4), ok: true},
+		{name: "zscore too few", detect: ZScore{}, latest: latest[:9]},
+		{name: "zscore min samples", detect: ZScore{MinSamples: 2}, latest: []float64{1, 3}, lo: -1, hi: 5, ok: true},
+		{name: "zscore no spread", detect: ZScore{}, latest: same, lo: 5, hi: 5, ok: true},
+		{name: "zscore min spread", detect: ZScore{MinSpread: 1}, latest: same, lo: 2, hi: 8, ok: true},
+		{name: "iqr", detect: IQR{}, latest: latest, lo: 99.25 - 1.5*2.5, hi: 101.75 + 1.5*2.5, ok: true},
+		{name: "iqr k", detect: IQR{K: 3}, latest: latest, lo: 99.25 - 3*2.5, hi: 101.75 + 3*2.5, ok: true},
+		{name: "iqr too few", detect: IQR{}},
+		{name: "iqr no spread", detect: IQR{}, latest: same, lo: 5, hi: 5, ok: true},
+		{name: "iqr min spread", detect: IQR{MinSpread: 2}, latest: same, lo: 2, hi: 8, ok: true},
+		{name: "mad", detect: MAD{}, latest: latest, lo: 100 - 3.5*1.4826*1.5, hi: 100 + 3.5*1.4826*1.5, ok: true},
+		{name: "mad max", detect: MAD{Max: 1}, latest: latest, lo: 100 - 1.4826*1.5, hi: 100 + 1.4826*1.5, ok: true},
+		{name: "mad too few", detect: MAD{}, latest: latest[:1]},
+		{name: "mad no spread", detect: MAD{}, latest: same, lo: 5, hi: 5, ok: true},
+		{name: "mad mostly the same", detect: MAD{}, latest: []float64{5, 5, 5, 5, 5, 5, 5, 5, 5, 10}, lo: 5, hi: 5, ok: true},
+		{name: "mad min spread", detect: MAD{MinSpread: 2}, latest: same, lo: -2, hi: 12, ok: true},
+		{name: "range", detect: Range{Min: 1, Max: 1000}, lo: 1, hi: 1000, ok: true},
+	}
+
+	for _, tc := range testCases {
+		lo, hi, ok := tc.detect.Bounds(tc.latest)
+		if expected, got := tc.ok, ok; expected != got {
+			t.Errorf("[%s] Expected ok %v but got %v", tc.name, expected, got)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if expected, got := tc.lo, lo; math.Abs(expected-got) > 1e-9 {
+			t.Errorf("[%s] Expected lo %v but got %v", tc.name, expected, got)
+		}
+		if expected, got := tc.hi, hi; math.Abs(expected-got) > 1e-9 {
+			t.Errorf("[%s] Expected hi %v but got %v", tc.name, expected, got)
+		}
+	}
+}
+
+// addAll adds the actions to s, failing on any error.
+func addAll(t *testing.T, s *ScoreKeeper, scoreType string, actions ...string) {
+	t.Helper()
+
+	for _, a := range actions {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatalf("failed to add %s: %v", a, err)
+		}
+	}
+}
+
+// normal hops, from 96 to 104, all by ann
+var normal = []string{
+	`{"action":"hop", "time":96, "player":"ann"}`,
+	`{"action":"hop", "time":98, "player":"ann"}`,
+	`{"action":"hop", "time":99, "player":"ann"}`,
+	`{"action":"hop", "time":100, "player":"ann"}`,
+	`{"action":"hop", "time":100, "player":"ann"}`,
+	`{"action":"hop", "time":100, "player":"ann"}`,
+	`{"action":"hop", "time":101, "player":"ann"}`,
+	`{"action":"hop", "time":102, "player":"ann"}`,
+	`{"action":"hop", "time":102, "player":"ann"}`,
+	`{"action":"hop", "time":102, "player":"ann"}`,
+}
+
+func TestOutlierNoSpread(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	testCases := []struct {
+		name   string
+		detect OutlierDetector
+		kept   []float64
+		avg    float64
+	}{
+		// values that have only ever been the same leave no room for others
+		{name: "mad", detect: MAD{}, avg: 100},
+		{name: "mad min spread", detect: MAD{MinSpread: 10}, kept: []float64{120}, avg: 2120.0 / 21},
+		{name: "zscore", detect: ZScore{}, avg: 100},
+		{name: "iqr", detect: IQR{}, avg: 100},
+	}
+
+	for _, tc := range testCases {
+		s, err := New(store.NewMemoryStore(), factory, WithOutlierPolicy("trial", OutlierPolicy{Detect: tc.detect}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 20; i++ {
+			addAll(t, s, "trial", `{"action":"hop", "time":100}`)
+		}
+
+		for _, v := range []float64{120, 9999999} {
+			err := s.AddAction("trial", fmt.Sprintf(`{"action":"hop", "time":%v}`, v))
+			kept := false
+			for _, k := range tc.kept {
+				kept = kept || k == v
+			}
+			if expected, got := kept, err == nil; expected != got {
+				t.Errorf("[%s] Expected %v to be kept: %t, but got '%v'", tc.name, v, expected, err)
+			}
+		}
+
+		res, err := s.GetStats("trial", "avg")
+		if err != nil {
+			t.Fatalf("[%s] %v", tc.name, err)
+		}
+		if expected, got := fmt.Sprintf(`[{"action":"hop","avg":%v}]`, tc.avg), res; expected != got {
+			t.Errorf("[%s] Expected '%s' but got '%s'", tc.name, expected, got)
+		}
+
+		s.Stop()
+	}
+}
+
+func TestOutlierReject(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	st := store.NewMemoryStore()
+	s, err := New(st, factory, WithOutlierPolicy("trial", OutlierPolicy{Detect: MAD{}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// too few to judge yet, so every score is kept
+	addAll(t, s, "trial", normal...)
+
+	err = s.AddAction("trial", `{"action":"hop", "time":9999999}`)
+	if !errors.Is(err, ErrOutlier) {
+		t.Fatalf("Expected error to be '%v' but got '%v'", ErrOutlier, err)
+	}
+	var oe *OutlierError
+	if !errors.As(err, &oe) {
+		t.Fatalf("Expected an *OutlierError but got %T", err)
+	}
+	if expected, got := (OutlierError{ScoreType: "trial", Action: "hop", Value: 9999999, Min: oe.Min, Max: oe.Max}), *oe; expected != got {
+		t.Errorf("Expected %+v but got %+v", expected, got)
+	}
+	if !(oe.Min < 96 && oe.Max > 102 && oe.Max < 200) {
+		t.Errorf("Expected bounds around the normal hops but got [%v, %v]", oe.Min, oe.Max)
+	}
+
+	// other actions are judged by their own values
+	if err := s.AddAction("trial", `{"action":"jump", "time":9999999}`); err != nil {
+		t.Errorf("Expected the first jump to be kept but got '%v'", err)
+	}
+
+	errs, err := s.AddActions("trial", []string{
+		`{"action":"hop", "time":101}`,
+		`{"action":"hop", "time":"fast"}`,
+		`{"action":"hop", "time":-5000}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 3, len(errs); expected != got {
+		t.Fatalf("Expected %d errors but got %d", expected, got)
+	}
+	if errs[0] != nil || !errors.Is(errs[1], score.ErrBadTime) || !errors.Is(errs[2], ErrOutlier) {
+		t.Errorf("Expected errors [nil, '%v', '%v'] but got %v", score.ErrBadTime, ErrOutlier, errs)
+	}
+
+	if expected, got := 12, st.Len("trial"); expected != got {
+		t.Errorf("Expected %d scores kept but got %d", expected, got)
+	}
+	res, err := s.GetStats("trial", "max")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"hop","max":102},{"action":"jump","max":9999999}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestOutlierFlag(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scores.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sqlite, err := store.NewSQLStore(db, store.WithDialect(store.SQLite), store.WithScoreFactory(factory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// kept in memory, and in the database
+	for _, st := range []store.ScoreStore{store.NewMemoryStore(), sqlite} {
+		s, err := New(st, factory, WithOutlierPolicy("trial", OutlierPolicy{Detect: IQR{}, Action: FlagOutliers}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		addAll(t, s, "trial", normal...)
+
+		// a record, if it weren't an outlier
+		res, err := s.AddActionWithResult("trial", `{"action":"hop", "time":1, "player":"bob"}`)
+		if err != nil {
+			t.Fatalf("Expected a flagged outlier to be kept but got '%v'", err)
+		}
+		if res.NewRecord || res.PersonalBest {
+			t.Errorf("Expected an outlier not to set a record but got %+v", res)
+		}
+
+		// flagged outliers are kept, but left out of stats and leaderboards, even after a restart
+		for _, restart := range []bool{false, true} {
+			if restart {
+				if err := s.Stop(); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Start(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stats, err := s.GetStats("trial", "min", "count")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected, got := `[{"action":"hop","count":10,"min":96}]`, stats; expected != got {
+				t.Errorf("[restart %v] Expected '%s' but got '%s'", restart, expected, got)
+			}
+
+			lb, err := s.Leaderboard("trial", "hop", store.Best, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected, got := 1, len(lb.Standings); expected != got {
+				t.Errorf("[restart %v] Expected %d player on the leaderboard but got %+v", restart, expected, lb.Standings)
+			}
+		}
+
+		scores, err := st.Retrieve(context.Background(), factory, "trial", store.Query{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		flagged := 0
+		for _, s := range scores["hop"] {
+			if score.IsOutlier(s) {
+				flagged++
+			}
+		}
+		if expected, got := 1, flagged; expected != got {
+			t.Errorf("Expected %d flagged score in the store but got %d", expected, got)
+		}
+
+		if err := s.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOutlierClamp(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	s, err := New(nil, factory, WithOutlierPolicy("trial", OutlierPolicy{Detect: Range{Min: 10, Max: 1000}, Action: ClampOutliers}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	addAll(t, s, "trial",
+		`{"action":"hop", "time":9999999}`,
+		`{"action":"hop", "time":1}`,
+		`{"action":"hop", "time":100}`,
+	)
+
+	res, err := s.GetStats("trial", "min", "max", "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"hop","count":3,"max":1000,"min":10}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestOutlierPolicyInvalid(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	type testCase struct {
+		name      string
+		scoreType string
+		policy    OutlierPolicy
+	}
+
+	testCases := []testCase{
+		{name: "unknown scoreType", scoreType: "race", policy: OutlierPolicy{Detect: ZScore{}}},
+		{name: "no detector", scoreType: "trial", policy: OutlierPolicy{}},
+		{name: "unknown action", scoreType: "trial", policy: OutlierPolicy{Detect: ZScore{}, Action: OutlierAction(7)}},
+	}
+
+	for _, tc := range testCases {
+		if _, err := New(nil, factory, WithOutlierPolicy(tc.scoreType, tc.policy)); !errors.Is(err, ErrBadOutlierPolicy) {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, ErrBadOutlierPolicy, err)
+		}
+	}
+}
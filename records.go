@@ -0,0 +1,187 @@
+package scorekeeper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// AddActionResult tells whether a score set a record, for its action and for its player.
+// A record is the lowest value, or the highest for a score.Higher.
+type AddActionResult struct {
+	// NewRecord is set if the score beat the best score of every player for its action.
+	NewRecord bool
+	// Previous best score for the action, unless First.
+	Previous float64
+	// First is set if the score was the first for its action.
+	First bool
+
+	// PersonalBest is set if the score beat its player's own best for the action.
+	// Scores without a player have no personal bests.
+	PersonalBest bool
+	// PreviousPersonal best score of the player, unless FirstPersonal.
+	PreviousPersonal float64
+	// FirstPersonal is set if the score was its player's first for the action.
+	FirstPersonal bool
+}
+
+// RecordEvent is published to subscribers when a score sets a new record or a personal best.
+type RecordEvent struct {
+	ScoreType string
+	Action    string
+	Player    string
+	Value     float64
+	// Recorded is when ScoreKeeper took the score in.
+	Recorded time.Time
+
+	AddActionResult
+}
+
+// bests keeps the best score of one action, overall and by player.
+type bests struct {
+	higher   bool
+	set      bool
+	best     float64
+	personal map[string]float64
+}
+
+// better reports whether value x beats value y.
+func (b *bests) better(x, y float64) bool {
+	if b.higher {
+		return x > y
+	}
+	return x < y
+}
+
+// records keeps the best scores of each action of each scoreType.
+// Only the worker touches records, so it needs no locking.
+type records struct {
+	// bests by scoreType and action
+	m map[string]map[string]*bests
+}
+
+func newRecords() *records {
+	return &records{m: map[string]map[string]*bests{}}
+}
+
+// step a newly stored score into the records, returning whether it set any.
+func (r *records) step(s score.Score) AddActionResult {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return AddActionResult{}
+	}
+
+	scoreType, action := s.Type(), s.Name()
+	if r.m[scoreType] == nil {
+		r.m[scoreType] = map[string]*bests{}
+	}
+	b := r.m[scoreType][action]
+	if b == nil {
+		b = &bests{higher: score.HigherIsBetter(s), personal: map[string]float64{}}
+		r.m[scoreType][action] = b
+	}
+
+	var res AddActionResult
+	if !b.set {
+		res.First = true
+		b.best, b.set = v, true
+	} else {
+		res.Previous = b.best
+		if b.better(v, b.best) {
+			res.NewRecord = true
+			b.best = v
+		}
+	}
+
+	player := score.PlayerOf(s)
+	if player == "" {
+		return res
+	}
+
+	previous, ok := b.personal[player]
+	if !ok {
+		res.FirstPersonal = true
+		b.personal[player] = v
+		return res
+	}
+
+	res.PreviousPersonal = previous
+	if b.better(v, previous) {
+		res.PersonalBest = true
+		b.personal[player] = v
+	}
+
+	return res
+}
+
+// recordSubscribers are the channels RecordEvents are published to.
+// The worker publishes and callers subscribe, so it is locked.
+type recordSubscribers struct {
+	mu   sync.Mutex
+	subs map[chan RecordEvent]struct{}
+}
+
+// publish e to every subscriber with room for it.
+// The worker never waits on a subscriber, so a full subscriber misses the event.
+func (rs *recordSubscribers) publish(e RecordEvent) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for ch := range rs.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (rs *recordSubscribers) add(ch chan RecordEvent) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.subs == nil {
+		rs.subs = map[chan RecordEvent]struct{}{}
+	}
+	rs.subs[ch] = struct{}{}
+}
+
+// remove and close ch, once nothing can publish to it.
+func (rs *recordSubscribers) remove(ch chan RecordEvent) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	delete(rs.subs, ch)
+	close(ch)
+}
+
+// SubscribeRecords returns a channel of the RecordEvents of every scoreType, as scores set them.
+// The channel holds up to buffer events; events published while it is full are dropped, so keep up.
+// It is closed when ctx is done or the ScoreKeeper stops. A negative buffer is an ErrBadSubscription.
+func (sk *ScoreKeeper) SubscribeRecords(ctx context.Context, buffer int) (<-chan RecordEvent, error) {
+	if sk.s == nil {
+		return nil, ErrNoKeeper
+	}
+	if buffer < 0 {
+		return nil, fmt.Errorf("%w: negative buffer", ErrBadSubscription)
+	}
+	w, err := sk.channels()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan RecordEvent, buffer)
+	sk.recordSubs.add(ch)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.quit:
+		}
+		sk.recordSubs.remove(ch)
+	}()
+
+	return ch, nil
+}
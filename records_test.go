@@ -0,0 +1,220 @@
+package scorekeeper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+func TestAddActionWithResult(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial":  func() score.Score { return &score.Trial{} },
+		"points": func() score.Score { return &points{} },
+	}
+
+	st := &store.MemoryStore{}
+	s, err := New(st, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	type testCase struct {
+		name      string
+		scoreType string
+		action    string
+		res       AddActionResult
+	}
+
+	// cases run in order, each seeing the records set before it
+	testCases := []testCase{
+		{
+			name:      "first",
+			scoreType: "trial",
+			action:    `{"action":"hop", "time":100, "player":"ann"}`,
+			res:       AddActionResult{First: true, FirstPersonal: true},
+		},
+		{
+			name:      "slower",
+			scoreType: "trial",
+			action:    `{"action":"hop", "time":150, "player":"ann"}`,
+			res:       AddActionResult{Previous: 100, PreviousPersonal: 100},
+		},
+		{
+			name:      "first of another player",
+			scoreType: "trial",
+			action:    `{"action":"hop", "time":120, "player":"bob"}`,
+			res:       AddActionResult{Previous: 100, FirstPersonal: true},
+		},
+		{
+			name:      "personal best",
+			scoreType: "trial",
+			action:    `{"action":"hop", "time":110, "player":"bob"}`,
+			res:       AddActionResult{Previous: 100, PersonalBest: true, PreviousPersonal: 120},
+		},
+		{
+			name:      "tie",
+			scoreType: "trial",
+			action:    `{"action":"hop", "time":100, "player":"bob"}`,
+			res:       AddActionResult{Previous: 100, PersonalBest: true, PreviousPersonal: 110},
+		},
+		{
+			name:      "new record",
+			scoreType: "trial",
+			action:    `{"action":"hop", "time":90, "player":"ann"}`,
+			res:       AddActionResult{NewRecord: true, Previous: 100, PersonalBest: true, PreviousPersonal: 100},
+		},
+		{
+			name:      "no player",
+			scoreType: "trial",
+			action:    `{"action":"hop", "time":80}`,
+			res:       AddActionResult{NewRecord: true, Previous: 90},
+		},
+		{
+			name:      "other action",
+			scoreType: "trial",
+			action:    `{"action":"jump", "time":500, "player":"ann"}`,
+			res:       AddActionResult{First: true, FirstPersonal: true},
+		},
+		{
+			name:      "higher is better",
+			scoreType: "points",
+			action:    `{"action":"hop", "time":10, "player":"ann"}`,
+			res:       AddActionResult{First: true, FirstPersonal: true},
+		},
+		{
+			name:      "higher record",
+			scoreType: "points",
+			action:    `{"action":"hop", "time":20, "player":"ann"}`,
+			res:       AddActionResult{NewRecord: true, Previous: 10, PersonalBest: true, PreviousPersonal: 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		res, err := s.AddActionWithResult(tc.scoreType, tc.action)
+		if err != nil {
+			t.Errorf("[%s] Expected no error but got '%v'", tc.name, err)
+		}
+		if expected, got := tc.res, res; expected != got {
+			t.Errorf("[%s] Expected %+v but got %+v", tc.name, expected, got)
+		}
+	}
+
+	// the records are rebuilt from the store
+	s.Stop()
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.AddActionWithResult("trial", `{"action":"hop", "time":85, "player":"ann"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := (AddActionResult{Previous: 80, PersonalBest: true, PreviousPersonal: 90}), res; expected != got {
+		t.Errorf("Expected %+v after restarting but got %+v", expected, got)
+	}
+}
+
+func TestSubscribeRecords(t *testing.T) {
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	recorded := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	s, err := New(&store.MemoryStore{}, factory, WithClock(func() time.Time { return recorded }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SubscribeRecords(context.Background(), 10); err != ErrNotRunning {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrNotRunning, err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if _, err := s.SubscribeRecords(context.Background(), -1); !errors.Is(err, ErrBadSubscription) {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrBadSubscription, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := s.SubscribeRecords(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range []string{
+		`{"action":"hop", "time":100, "player":"ann"}`,
+		`{"action":"hop", "time":150, "player":"ann"}`,
+		`{"action":"hop", "time":120, "player":"bob"}`,
+		`{"action":"hop", "time":110, "player":"bob"}`,
+		`{"action":"hop", "time":90, "player":"bob"}`,
+	} {
+		if err := s.AddAction("trial", a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// firsts and slower scores aren't published
+	expected := []RecordEvent{
+		{
+			ScoreType:       "trial",
+			Action:          "hop",
+			Player:          "bob",
+			Value:           110,
+			Recorded:        recorded,
+			AddActionResult: AddActionResult{Previous: 100, PersonalBest: true, PreviousPersonal: 120},
+		},
+		{
+			ScoreType:       "trial",
+			Action:          "hop",
+			Player:          "bob",
+			Value:           90,
+			Recorded:        recorded,
+			AddActionResult: AddActionResult{NewRecord: true, Previous: 100, PersonalBest: true, PreviousPersonal: 110},
+		},
+	}
+	for i, e := range expected {
+		select {
+		case got := <-events:
+			if e != got {
+				t.Errorf("[%d] Expected %+v but got %+v", i, e, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("[%d] Expected an event", i)
+		}
+	}
+
+	cancel()
+	select {
+	case e, ok := <-events:
+		if ok {
+			t.Errorf("Expected no more events but got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the events to be closed once ctx was done")
+	}
+
+	// stopping closes subscriptions too
+	events, err = s.SubscribeRecords(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected no events")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the events to be closed once the ScoreKeeper stopped")
+	}
+}
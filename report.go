@@ -0,0 +1,164 @@
+package scorekeeper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bdharris08/scorekeeper/stat"
+)
+
+// StatsReport is the stats for each action of a scoreType.
+type StatsReport struct {
+	ScoreType string
+	// Actions ordered by action name.
+	Actions []ActionStats
+}
+
+// ActionStats is the stats for one action.
+type ActionStats struct {
+	Action string
+	// Count of the scores the stats were computed over.
+	Count int
+	// Values of the stats by name, like "avg" or "p99".
+	// A stat over a window with no scores in it has a nil value.
+	Values map[string]interface{}
+}
+
+// Float returns the named stat as a float64, if it is one.
+func (a ActionStats) Float(name string) (float64, bool) {
+	f, ok := a.Values[name].(float64)
+	return f, ok
+}
+
+// JSON encodes the report as a json list with one object per action, like GetStats:
+// `[{"action":"hop","avg":100}]`.
+func (r StatsReport) JSON() (string, error) {
+	rows := make([]map[string]interface{}, 0, len(r.Actions))
+	for _, a := range r.Actions {
+		row := make(map[string]interface{}, len(a.Values)+1)
+		for name, v := range a.Values {
+			row[name] = v
+		}
+		row["action"] = a.Action
+
+		rows = append(rows, row)
+	}
+
+	b, err := json.Marshal(rows)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// Order sorts the actions of a StatsReport.
+type Order struct {
+	// By is the name of the stat to sort by, or "action" for the action name, or "count" for the score count.
+	By string
+	// Desc sorts from the highest value down.
+	Desc bool
+}
+
+var (
+	// ByAction sorts actions by name. Reports are in this order to begin with.
+	ByAction = Order{By: "action"}
+	// ByAvg sorts actions from the lowest average up.
+	ByAvg = Order{By: "avg"}
+	// ByAvgDesc sorts actions from the highest average down.
+	ByAvgDesc = Order{By: "avg", Desc: true}
+	// ByCount sorts actions from the most scores down.
+	ByCount = Order{By: "count", Desc: true}
+)
+
+// stat returns the name of the stat o sorts by, or "" if it sorts by action name or count.
+func (o Order) stat() string {
+	if o.By == "action" || o.By == "count" || o.By == "" {
+		return ""
+	}
+	return o.By
+}
+
+// Sort returns a copy of the report with its actions in order o.
+// Ties are broken by action name. Actions without a number for the stat, like an empty window, go last.
+func (r StatsReport) Sort(o Order) StatsReport {
+	actions := make([]ActionStats, len(r.Actions))
+	copy(actions, r.Actions)
+
+	less := func(a, b ActionStats) bool {
+		switch o.By {
+		case "action", "":
+			if o.Desc {
+				return a.Action > b.Action
+			}
+			return a.Action < b.Action
+		case "count":
+			if a.Count != b.Count {
+				return (a.Count < b.Count) != o.Desc
+			}
+		default:
+			av, aok := a.Float(o.By)
+			bv, bok := b.Float(o.By)
+			if aok != bok {
+				return aok
+			}
+			if aok && av != bv {
+				return (av < bv) != o.Desc
+			}
+		}
+
+		return a.Action < b.Action
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return less(actions[i], actions[j])
+	})
+
+	return StatsReport{ScoreType: r.ScoreType, Actions: actions}
+}
+
+// Top returns the report with only its first n actions, or every action if n isn't positive.
+func (r StatsReport) Top(n int) StatsReport {
+	if n <= 0 || n >= len(r.Actions) {
+		return r
+	}
+
+	return StatsReport{ScoreType: r.ScoreType, Actions: r.Actions[:n]}
+}
+
+// ErrNotHistogram is returned by Prometheus for a stat that isn't a stat.Histogram.
+var ErrNotHistogram = errors.New("not a histogram")
+
+// unsafeMetric matches the characters not allowed in a Prometheus metric name.
+var unsafeMetric = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// Prometheus renders the named histogram stat of every action in the Prometheus text format,
+// as one histogram called {scoreType}_{name} with an "action" label.
+// Actions without scores in the histogram's window are left out.
+func (r StatsReport) Prometheus(name string) (string, error) {
+	metric := unsafeMetric.ReplaceAllString(r.ScoreType+"_"+name, "_")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", metric)
+	for _, a := range r.Actions {
+		v, ok := a.Values[name]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", stat.ErrUnknownStat, name)
+		}
+		if v == nil {
+			continue
+		}
+
+		h, ok := v.(stat.HistogramReport)
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrNotHistogram, name)
+		}
+		b.WriteString(h.Prometheus(metric, map[string]string{"action": a.Action}))
+	}
+
+	return b.String(), nil
+}
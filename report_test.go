@@ -0,0 +1,170 @@
+package scorekeeper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+func TestStatsReportSort(t *testing.T) {
+	r := StatsReport{
+		ScoreType: "trial",
+		Actions: []ActionStats{
+			{Action: "hop", Count: 2, Values: map[string]interface{}{"avg": float64(15), "avg_5m": nil}},
+			{Action: "jump", Count: 3, Values: map[string]interface{}{"avg": float64(200), "avg_5m": float64(1)}},
+			{Action: "skip", Count: 3, Values: map[string]interface{}{"avg": float64(30), "avg_5m": float64(2)}},
+			{Action: "walk", Count: 1, Values: map[string]interface{}{"avg": float64(15), "avg_5m": float64(3)}},
+		},
+	}
+
+	type testCase struct {
+		name    string
+		order   Order
+		limit   int
+		actions []string
+	}
+
+	testCases := []testCase{
+		{
+			name:    "by action",
+			order:   ByAction,
+			actions: []string{"hop", "jump", "skip", "walk"},
+		},
+		{
+			name:    "by action descending",
+			order:   Order{By: "action", Desc: true},
+			actions: []string{"walk", "skip", "jump", "hop"},
+		},
+		{
+			name:    "by avg",
+			order:   ByAvg,
+			actions: []string{"hop", "walk", "skip", "jump"},
+		},
+		{
+			name:    "by avg descending",
+			order:   ByAvgDesc,
+			actions: []string{"jump", "skip", "hop", "walk"},
+		},
+		{
+			name:    "by count",
+			order:   ByCount,
+			actions: []string{"jump", "skip", "hop", "walk"},
+		},
+		{
+			name:    "empty window last",
+			order:   Order{By: "avg_5m", Desc: true},
+			actions: []string{"walk", "skip", "jump", "hop"},
+		},
+		{
+			name:    "missing stat",
+			order:   Order{By: "p99"},
+			actions: []string{"hop", "jump", "skip", "walk"},
+		},
+		{
+			name:    "top",
+			order:   ByAvg,
+			limit:   2,
+			actions: []string{"hop", "walk"},
+		},
+		{
+			name:    "top of more than there are",
+			order:   ByAvg,
+			limit:   10,
+			actions: []string{"hop", "walk", "skip", "jump"},
+		},
+	}
+
+	for _, tc := range testCases {
+		got := r.Sort(tc.order).Top(tc.limit)
+
+		if expected, got := len(tc.actions), len(got.Actions); expected != got {
+			t.Errorf("[%s] Expected %d actions but got %d", tc.name, expected, got)
+			continue
+		}
+		for i, action := range tc.actions {
+			if expected, got := action, got.Actions[i].Action; expected != got {
+				t.Errorf("[%s] Expected action %d to be %s but got %s", tc.name, i, expected, got)
+			}
+		}
+		if expected, got := r.ScoreType, got.ScoreType; expected != got {
+			t.Errorf("[%s] Expected scoreType %s but got %s", tc.name, expected, got)
+		}
+	}
+
+	// the report itself is left alone
+	if expected, got := "hop", r.Actions[0].Action; expected != got {
+		t.Errorf("Expected the report to keep its order but got %s first", got)
+	}
+}
+
+func TestStatsReportHistogram(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	s, err := New(&store.MemoryStore{}, factory, WithStats(stat.Factory{
+		"time": func() stat.Stat { return &stat.Histogram{Bounds: stat.LinearBuckets(100, 100, 2)} },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	for _, a := range []string{
+		`{"action":"jump", "time":100}`,
+		`{"action":"jump", "time":150}`,
+		`{"action":"jump", "time":250}`,
+		`{"action":"hop", "time":50}`,
+	} {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := s.GetStats(scoreType, "time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"hop","time":{"buckets":[{"le":100,"count":1},{"le":200,"count":0},{"le":"+Inf","count":0}],"count":1,"sum":50}},`+
+		`{"action":"jump","time":{"buckets":[{"le":100,"count":1},{"le":200,"count":1},{"le":"+Inf","count":1}],"count":3,"sum":500}}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+
+	r, err := s.Stats(scoreType, "time", "avg", "time_last1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prom, err := r.Prometheus("time_last1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `# TYPE trial_time_last1 histogram
+trial_time_last1_bucket{action="hop",le="100"} 1
+trial_time_last1_bucket{action="hop",le="200"} 1
+trial_time_last1_bucket{action="hop",le="+Inf"} 1
+trial_time_last1_sum{action="hop"} 50
+trial_time_last1_count{action="hop"} 1
+trial_time_last1_bucket{action="jump",le="100"} 0
+trial_time_last1_bucket{action="jump",le="200"} 0
+trial_time_last1_bucket{action="jump",le="+Inf"} 1
+trial_time_last1_sum{action="jump"} 250
+trial_time_last1_count{action="jump"} 1
+`, prom; expected != got {
+		t.Errorf("Expected prometheus\n%s\nbut got\n%s", expected, got)
+	}
+
+	if _, err := r.Prometheus("avg"); !errors.Is(err, ErrNotHistogram) {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrNotHistogram, err)
+	}
+	if _, err := r.Prometheus("p99"); !errors.Is(err, stat.ErrUnknownStat) {
+		t.Errorf("Expected error to be '%v' but got '%v'", stat.ErrUnknownStat, err)
+	}
+}
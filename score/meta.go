@@ -0,0 +1,100 @@
+package score
+
+import (
+	"errors"
+	"time"
+)
+
+// Meta is what a Score knows about itself beyond its name and value.
+// Embed Meta in a Score to let ScoreKeeper and the stores group it by cohort and time.
+type Meta struct {
+	// Cohort groups scores from the same run, session or season. Empty means no cohort.
+	Cohort string `json:"cohort,omitempty"`
+	// Player who scored, for leaderboards. Empty means no player.
+	Player string `json:"player,omitempty"`
+	// At is when the action happened, if the client said so.
+	At time.Time `json:"at"`
+	// Recorded is when ScoreKeeper took the score in.
+	Recorded time.Time `json:"-"`
+	// Outlier is set by ScoreKeeper on a score it kept but leaves out of stats, see scorekeeper.OutlierPolicy.
+	Outlier bool `json:"-"`
+}
+
+// When the action happened: At if the client said, otherwise when it was Recorded.
+func (m *Meta) When() time.Time {
+	if !m.At.IsZero() {
+		return m.At
+	}
+
+	return m.Recorded
+}
+
+// Metadata returns the Meta itself, making any Score that embeds Meta Annotated.
+func (m *Meta) Metadata() *Meta {
+	return m
+}
+
+// Annotated is a Score that carries Meta.
+type Annotated interface {
+	Metadata() *Meta
+}
+
+var ErrNoMeta = errors.New("score type has no metadata")
+
+// MetadataOf returns the Meta of s, or nil if s isn't Annotated.
+func MetadataOf(s Score) *Meta {
+	a, ok := s.(Annotated)
+	if !ok {
+		return nil
+	}
+
+	return a.Metadata()
+}
+
+// CohortOf returns the cohort of s, or "" if it has none.
+func CohortOf(s Score) string {
+	if m := MetadataOf(s); m != nil {
+		return m.Cohort
+	}
+
+	return ""
+}
+
+// PlayerOf returns the player of s, or "" if it has none.
+func PlayerOf(s Score) string {
+	if m := MetadataOf(s); m != nil {
+		return m.Player
+	}
+
+	return ""
+}
+
+// IsOutlier reports whether s was flagged as an outlier.
+func IsOutlier(s Score) bool {
+	if m := MetadataOf(s); m != nil {
+		return m.Outlier
+	}
+
+	return false
+}
+
+// WhenOf returns when the action scored by s happened, or the zero time if s isn't Annotated.
+func WhenOf(s Score) time.Time {
+	if m := MetadataOf(s); m != nil {
+		return m.When()
+	}
+
+	return time.Time{}
+}
+
+// Higher is a Score that is better the higher its value is, like points.
+// Scores that aren't Higher are better the lower their value is, like a Trial's time.
+type Higher interface {
+	HigherIsBetter() bool
+}
+
+// HigherIsBetter reports whether a higher value of s is better.
+func HigherIsBetter(s Score) bool {
+	h, ok := s.(Higher)
+	return ok && h.HigherIsBetter()
+}
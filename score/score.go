@@ -37,6 +37,8 @@ func Create(f ScoreFactory, scoreType string) (Score, error) {
 type TestScore struct {
 	TName  string
 	TValue float64
+
+	Meta
 }
 
 func NewTestScore() Score {
@@ -16,6 +16,8 @@ type Trial struct {
 	// We only need an int to store this data, but I don't know the edginess of the edge cases
 	// that will be used in testing.
 	Time float64 `json:"time"`
+
+	Meta
 }
 
 // AverageTime will be used to report an average time
@@ -31,6 +33,9 @@ var (
 	ErrBadScoreType = errors.New("invalid ScoreType")
 	ErrBadAction    = errors.New("invalid action")
 	ErrBadInput     = errors.New("bad input")
+	ErrBadCohort    = errors.New("invalid cohort")
+	ErrBadPlayer    = errors.New("invalid player")
+	ErrBadAt        = errors.New("invalid at, expected an RFC 3339 time")
 )
 
 func NewTrial() Score {
@@ -64,7 +69,12 @@ func (t *Trial) Set(name string, value interface{}) error {
 	return nil
 }
 
+// trialJSON is a Trial without its methods, for decoding.
+type trialJSON Trial
+
 // Read a json-encoded string into the Trial struct.
+// An optional "at" field gives the RFC 3339 time the action happened,
+// and an optional "player" who scored it.
 func (t *Trial) Read(action string) error {
 	if action == "" {
 		return ErrNoInput
@@ -74,7 +84,13 @@ func (t *Trial) Read(action string) error {
 		return ErrNoTime
 	}
 
-	err := json.Unmarshal([]byte(action), t)
+	// decode "at" separately, so a bad time can be told apart from other bad input
+	aux := struct {
+		*trialJSON
+		At json.RawMessage `json:"at"`
+	}{trialJSON: (*trialJSON)(t)}
+
+	err := json.Unmarshal([]byte(action), &aux)
 	if err != nil {
 		if jsonErr, ok := err.(*json.UnmarshalTypeError); ok {
 			switch jsonErr.Field {
@@ -82,6 +98,10 @@ func (t *Trial) Read(action string) error {
 				return ErrBadAction
 			case "time":
 				return ErrBadTime
+			case "cohort":
+				return ErrBadCohort
+			case "player":
+				return ErrBadPlayer
 			}
 		}
 
@@ -92,5 +112,11 @@ func (t *Trial) Read(action string) error {
 		return ErrBadAction
 	}
 
+	if len(aux.At) > 0 && string(aux.At) != "null" {
+		if err := json.Unmarshal(aux.At, &t.At); err != nil {
+			return ErrBadAt
+		}
+	}
+
 	return nil
 }
@@ -1,9 +1,11 @@
 package scorekeeper
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/bdharris08/scorekeeper/score"
 	"github.com/bdharris08/scorekeeper/stat"
@@ -18,6 +20,28 @@ type ScoreKeeper struct {
 	f score.ScoreFactory
 	// scoreStore for storing scores in
 	s store.ScoreStore
+	// stats names the statistics GetStats can compute
+	stats stat.Factory
+	// aggs keeps running stats for the worker, rebuilt by Start.
+	aggs *aggregates
+	// ranks keeps leaderboards for the worker, rebuilt by Start.
+	ranks *ranks
+	// records keeps the best scores for the worker, rebuilt by Start.
+	records *records
+	// outlierPolicies by scoreType, set by WithOutlierPolicy
+	outlierPolicies map[string]OutlierPolicy
+	// outliers screens scores for the worker by outlierPolicies, rebuilt by Start.
+	outliers *outliers
+	// recordSubs are sent the records the worker sees set.
+	recordSubs recordSubscribers
+	// statsSubs are sent StatsUpdates as the worker stores scores.
+	statsSubs statsSubscribers
+	// now tells the time scores are recorded at
+	now func() time.Time
+	// maxSkew is how far ahead of now a score's "at" may be
+	maxSkew time.Duration
+	// mu guards the worker channels below, which are replaced by Start and Stop.
+	mu sync.RWMutex
 	// Scores chan will allow clients (through AddAction) to send scores to the worker.
 	// Constrain scores channel to only receive, ensuring only the worker reads.
 	// errors can be returned by the included channel, like an addressed envelope in an envelope.
@@ -25,18 +49,70 @@ type ScoreKeeper struct {
 	// Requests chan will be used by clients (through GetStats) to request stats from the worker.
 	// Constrain requests channel to only receive, ensuring only the worker reads.
 	requests chan<- requestEnvelope
-	// close(exit) to stop the worker.
-	quit chan bool
+	// RankReqs chan will be used by clients (through Leaderboard) to request standings from the worker.
+	rankReqs chan<- rankEnvelope
+	// SketchReqs chan will be used by clients (through Sketch and MergeSketch) to reach the sketches of the worker.
+	sketchReqs chan<- sketchEnvelope
+	// close(quit) to stop the worker taking new envelopes.
+	quit chan struct{}
+	// done is closed by the worker when it exits.
+	done chan struct{}
+}
+
+// Option configures a ScoreKeeper in New.
+type Option func(sk *ScoreKeeper)
+
+// WithStats registers more stats for GetStats, alongside stat.Defaults.
+// A stat with the same name as a default replaces it.
+func WithStats(f stat.Factory) Option {
+	return func(sk *ScoreKeeper) {
+		for name, constructor := range f {
+			sk.stats[name] = constructor
+		}
+	}
+}
+
+// WithClock sets the clock used to timestamp scores as they are recorded. The default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(sk *ScoreKeeper) {
+		sk.now = now
+	}
+}
+
+// DefaultMaxSkew is how far ahead of the clock a score may say it happened, unless WithMaxSkew gives another bound.
+const DefaultMaxSkew = time.Minute
+
+// WithMaxSkew bounds how far ahead of the clock a score may say it happened, allowing for the skew of clients' clocks.
+// A score that happened later than that is rejected with ErrFutureAt.
+func WithMaxSkew(d time.Duration) Option {
+	return func(sk *ScoreKeeper) {
+		sk.maxSkew = d
+	}
+}
+
+var ErrFutureAt = errors.New("at is too far in the future")
+
+// checkAt rejects a score that says it happened after latest.
+func checkAt(s score.Score, latest time.Time) error {
+	if at := score.WhenOf(s); at.After(latest) {
+		return fmt.Errorf("%w: %s", ErrFutureAt, at.Format(time.RFC3339))
+	}
+
+	return nil
 }
 
 // New creates and returns a ScoreKeeper with the provided ScoreStore.
-// It starts
-func New(st store.ScoreStore, sf score.ScoreFactory) (*ScoreKeeper, error) {
-	sk := &ScoreKeeper{}
+// Use Start to start it.
+func New(st store.ScoreStore, sf score.ScoreFactory, opts ...Option) (*ScoreKeeper, error) {
+	sk := &ScoreKeeper{
+		stats:   stat.Defaults(),
+		now:     time.Now,
+		maxSkew: DefaultMaxSkew,
+	}
 
 	// default to memoryStore if none was provided
 	if st == nil {
-		st = &store.MemoryStore{S: map[string]map[string][]score.Score{}}
+		st = store.NewMemoryStore()
 	}
 	sk.s = st
 
@@ -45,24 +121,130 @@ func New(st store.ScoreStore, sf score.ScoreFactory) (*ScoreKeeper, error) {
 	}
 	sk.f = sf
 
+	for _, opt := range opts {
+		opt(sk)
+	}
+
+	if err := checkOutlierPolicies(sk.outlierPolicies, sk.f); err != nil {
+		return nil, err
+	}
+
 	return sk, nil
 }
 
 // Start a worker routine to listen on the scores channel.
-func (sk *ScoreKeeper) Start() {
-	sk.quit = make(chan bool)
-	sk.scores, sk.requests = sk.work()
+// Before the worker starts, the running stats asked for so far are rebuilt from the store.
+// Starting a running ScoreKeeper does nothing.
+func (sk *ScoreKeeper) Start() error {
+	sk.mu.Lock()
+	defer sk.mu.Unlock()
+
+	if sk.quit != nil && !closed(sk.quit) {
+		return nil
+	}
+	// a Stop in progress has closed quit, but its worker may still be stepping scores into what rebuild replaces.
+	// The worker never takes mu, so waiting for it here can't deadlock.
+	if sk.done != nil {
+		<-sk.done
+	}
+
+	if err := sk.rebuild(context.Background()); err != nil {
+		return fmt.Errorf("failed to rebuild stats: %w", err)
+	}
+
+	sk.quit = make(chan struct{})
+	sk.done = make(chan struct{})
+	sk.scores, sk.requests, sk.rankReqs, sk.sketchReqs = sk.work(sk.quit, sk.done)
+	return nil
+}
+
+// rebuild the running stats, leaderboards and records from every score in the store.
+// Only the stats asked for before a restart are rebuilt; others start running when first asked for.
+// Scores written to the store by anyone but this ScoreKeeper are only seen after a rebuild.
+func (sk *ScoreKeeper) rebuild(ctx context.Context) error {
+	var tracked map[string][]string
+	if sk.aggs != nil {
+		tracked = sk.aggs.tracked
+	}
+
+	sk.aggs = newAggregates(sk.stats, sk.now)
+	sk.ranks = newRanks()
+	sk.records = newRecords()
+	sk.outliers = newOutliers(sk.outlierPolicies)
+	for scoreType := range sk.f {
+		scoreMap, err := sk.s.Retrieve(ctx, sk.f, scoreType, store.Query{})
+		if err != nil && !errors.Is(err, store.ErrNoScores) {
+			return err
+		}
+
+		if names := tracked[scoreType]; len(names) > 0 {
+			if err := sk.aggs.track(scoreType, names, scoreMap); err != nil {
+				return err
+			}
+		}
+		for _, scores := range scoreMap {
+			for _, s := range scores {
+				if score.IsOutlier(s) {
+					continue
+				}
+				sk.ranks.step(s)
+				sk.records.step(s)
+				sk.outliers.step(s)
+			}
+		}
+	}
+
+	return nil
 }
 
 var ErrNotRunning = errors.New("scorekeeper not running. Use Start()")
 
 // Stop the worker goroutine.
+// The worker stops taking new envelopes and finishes the one in hand.
+// Stop returns once the worker has exited.
 func (sk *ScoreKeeper) Stop() error {
-	if sk.quit != nil {
-		close(sk.quit)
-		return nil
+	sk.mu.Lock()
+	if sk.quit == nil || closed(sk.quit) {
+		sk.mu.Unlock()
+		return ErrNotRunning
+	}
+	close(sk.quit)
+	done := sk.done
+	sk.mu.Unlock()
+
+	<-done
+	return nil
+}
+
+// closed reports whether ch has been closed.
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
 	}
-	return ErrNotRunning
+}
+
+// worker is the channels of a running worker.
+type worker struct {
+	scores   chan<- scoreEnvelope
+	requests chan<- requestEnvelope
+	ranks    chan<- rankEnvelope
+	sketches chan<- sketchEnvelope
+	quit     <-chan struct{}
+}
+
+// channels returns the running worker's channels, or ErrNotRunning.
+func (sk *ScoreKeeper) channels() (worker, error) {
+	sk.mu.RLock()
+	defer sk.mu.RUnlock()
+
+	if sk.quit == nil || closed(sk.quit) {
+		return worker{}, ErrNotRunning
+	}
+
+	return worker{scores: sk.scores, requests: sk.requests, ranks: sk.rankReqs, sketches: sk.sketchReqs, quit: sk.quit}, nil
 }
 
 // ValidScoreType checks for the presence of scoreType in the score factory
@@ -73,147 +255,512 @@ func ValidScoreType(sk *ScoreKeeper, scoreType string) bool {
 
 // result stats from the scorekeeper, or an error
 type result struct {
-	result string
+	report StatsReport
 	err    error
 }
 
-// scoreEnvelope allows the caller to send a Score and receive an error from the worker
+// scoreEnvelope allows the caller to send Scores and receive the results or an error from the worker.
+// The context travels with the envelope so the store can give up when the caller does.
 type scoreEnvelope struct {
-	score score.Score
-	err   chan error
+	ctx    context.Context
+	scores []score.Score
+	r      chan addResult
+}
+
+// addResult of each stored score, or an error.
+// rejected has the error for each score rejected as an outlier by index, or is nil if none were.
+type addResult struct {
+	results  []AddActionResult
+	rejected []error
+	err      error
 }
 
 // requestEnvelope encapsulates a request for a type of score and a channel to receive the result
 type requestEnvelope struct {
+	ctx       context.Context
+	scoreType string
+	query     store.Query
+	stats     []string
+	// sub, if set, is subscribed by the worker along with the report
+	sub *subscriber
+	r   chan result
+}
+
+// rankResult standings from the scorekeeper, or an error
+type rankResult struct {
+	standings []store.Standing
+	err       error
+}
+
+// rankEnvelope encapsulates a request for standings and a channel to receive them
+type rankEnvelope struct {
+	ctx       context.Context
 	scoreType string
-	r         chan result
+	query     store.RankQuery
+	r         chan rankResult
 }
 
 // work on new scores sent from AddAction.
-func (sk *ScoreKeeper) work() (chan<- scoreEnvelope, chan<- requestEnvelope) {
+// The worker blocks until there is an envelope to handle or quit is closed,
+// then closes done on its way out.
+func (sk *ScoreKeeper) work(quit <-chan struct{}, done chan<- struct{}) (chan<- scoreEnvelope, chan<- requestEnvelope, chan<- rankEnvelope, chan<- sketchEnvelope) {
 	scores := make(chan scoreEnvelope)
 	requests := make(chan requestEnvelope)
+	rankReqs := make(chan rankEnvelope)
+	sketchReqs := make(chan sketchEnvelope)
 	go func() {
+		defer close(done)
 		for {
+			// prefer quitting over taking another envelope
+			if closed(quit) {
+				return
+			}
+
 			select {
-			case <-sk.quit:
+			case <-quit:
 				return
 
 			case s := <-scores:
-				err := sk.s.Store(s.score)
-				s.err <- err
+				now := sk.now()
+				kept, rejected := sk.screen(s.scores, now)
+				results, err := sk.store(s.ctx, kept, now)
+				sk.updated(kept[:len(results)])
+				s.r <- addResult{
+					results:  results,
+					rejected: rejected,
+					err:      err,
+				}
 
 			case re := <-requests:
-				res, err := sk.get(re.scoreType)
+				res, err := sk.get(re.ctx, re.scoreType, re.query, re.stats)
+				if re.sub != nil {
+					err = sk.subscribe(re.sub, res, err)
+				}
 				re.r <- result{
-					result: res,
+					report: res,
 					err:    err,
 				}
 
-			default:
-				// loop until quit
+			case re := <-rankReqs:
+				standings, err := sk.standings(re.ctx, re.scoreType, re.query)
+				re.r <- rankResult{
+					standings: standings,
+					err:       err,
+				}
+
+			case re := <-sketchReqs:
+				s, err := sk.sketched(re.ctx, re.scoreType, re.action, re.stat, re.merge)
+				re.r <- sketchResult{
+					sketch: s,
+					err:    err,
+				}
 			}
 		}
 	}()
-	return scores, requests
+	return scores, requests, rankReqs, sketchReqs
+}
+
+// store timestamps scores with now and keeps them, stepping each one kept into the running stats, leaderboards and records.
+// Several scores go to a store.BatchStore all at once.
+// It returns whether each score kept set a record.
+func (sk *ScoreKeeper) store(ctx context.Context, ss []score.Score, now time.Time) ([]AddActionResult, error) {
+	for _, s := range ss {
+		if m := score.MetadataOf(s); m != nil {
+			m.Recorded = now
+		}
+	}
+
+	results := make([]AddActionResult, 0, len(ss))
+
+	if bs, ok := sk.s.(store.BatchStore); ok && len(ss) > 1 {
+		if err := bs.StoreBatch(ctx, ss); err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			results = append(results, sk.stored(s, now))
+		}
+		return results, nil
+	}
+
+	for _, s := range ss {
+		if err := sk.s.Store(ctx, s); err != nil {
+			return results, err
+		}
+		results = append(results, sk.stored(s, now))
+	}
+	return results, nil
+}
+
+// stored steps a score that was just stored into the running stats, leaderboards and records,
+// publishing any record it set. Outliers are left out.
+func (sk *ScoreKeeper) stored(s score.Score, recorded time.Time) AddActionResult {
+	if score.IsOutlier(s) {
+		return AddActionResult{}
+	}
+
+	sk.aggs.step(s)
+	sk.ranks.step(s)
+	sk.outliers.step(s)
+
+	res := sk.records.step(s)
+	if res.NewRecord || res.PersonalBest {
+		v, _ := s.Value().(float64)
+		sk.recordSubs.publish(RecordEvent{
+			ScoreType:       s.Type(),
+			Action:          s.Name(),
+			Player:          score.PlayerOf(s),
+			Value:           v,
+			Recorded:        recorded,
+			AddActionResult: res,
+		})
+	}
+
+	return res
 }
 
 var ErrNoKeeper = errors.New("scorekeeper uninitialized. Use New()")
 
 // AddAction takes a json-encoded string action and keeps it for later.
 func (sk *ScoreKeeper) AddAction(scoreType, action string) error {
+	return sk.AddActionContext(context.Background(), scoreType, action)
+}
+
+// AddActionContext is AddAction with a context.
+// It gives up and returns ctx.Err() if the context is done before the score is stored.
+func (sk *ScoreKeeper) AddActionContext(ctx context.Context, scoreType, action string) error {
+	_, err := sk.add(ctx, scoreType, action, "")
+	return err
+}
+
+// AddActionWithResult is AddAction, also returning whether the score set a new record for its action,
+// or a personal best for its player. Records are published to SubscribeRecords too.
+func (sk *ScoreKeeper) AddActionWithResult(scoreType, action string) (AddActionResult, error) {
+	return sk.AddActionWithResultContext(context.Background(), scoreType, action)
+}
+
+// AddActionWithResultContext is AddActionWithResult with a context.
+// It gives up and returns ctx.Err() if the context is done before the score is stored.
+func (sk *ScoreKeeper) AddActionWithResultContext(ctx context.Context, scoreType, action string) (AddActionResult, error) {
+	return sk.add(ctx, scoreType, action, "")
+}
+
+// AddCohortAction is AddAction for a score that belongs to a cohort,
+// like a single run, session or season, so its stats can be kept apart with GetCohortStats.
+// The cohort replaces any "cohort" in the action. The scoreType must embed score.Meta.
+func (sk *ScoreKeeper) AddCohortAction(scoreType, cohort, action string) error {
+	_, err := sk.add(context.Background(), scoreType, action, cohort)
+	return err
+}
+
+// AddActions keeps many json-encoded actions of one scoreType, like AddAction,
+// sending them to the worker together and, if the store is a store.BatchStore, storing them together.
+// Every action is read before any is kept, and the valid ones are kept even if others are not.
+// errs is nil if every action was valid and kept, otherwise it has the error for each action by index, nil for those kept.
+// An action rejected by an OutlierPolicy has an *OutlierError, and one too far in the future ErrFutureAt.
+// err is for the batch as a whole, like ErrNotRunning or a failing store.
+// If the store is not a BatchStore, a failing store may have kept some of the actions.
+func (sk *ScoreKeeper) AddActions(scoreType string, actions []string) (errs []error, err error) {
+	return sk.AddActionsContext(context.Background(), scoreType, actions)
+}
+
+// AddActionsContext is AddActions with a context.
+// It gives up and returns ctx.Err() if the context is done before the scores are stored.
+func (sk *ScoreKeeper) AddActionsContext(ctx context.Context, scoreType string, actions []string) ([]error, error) {
 	if sk.s == nil {
-		return ErrNoKeeper
+		return nil, ErrNoKeeper
 	}
-	if sk.scores == nil || sk.quit == nil {
-		return ErrNotRunning
+	if _, err := sk.channels(); err != nil {
+		return nil, err
+	}
+	if valid := ValidScoreType(sk, scoreType); !valid {
+		return nil, score.ErrBadScoreType
+	}
+
+	var errs []error
+	ss := make([]score.Score, 0, len(actions))
+	// index of each score in actions
+	index := make([]int, 0, len(actions))
+	for i, action := range actions {
+		s, err := sk.read(scoreType, action, "")
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(actions))
+			}
+			errs[i] = err
+			continue
+		}
+		ss = append(ss, s)
+		index = append(index, i)
+	}
+
+	if len(ss) == 0 {
+		return errs, nil
+	}
+
+	_, rejected, err := sk.send(ctx, ss)
+	for i, rej := range rejected {
+		if rej == nil {
+			continue
+		}
+		if errs == nil {
+			errs = make([]error, len(actions))
+		}
+		errs[index[i]] = rej
+	}
+	return errs, err
+}
+
+// add reads the action into a score, tags it with cohort if there is one, and sends it to the worker.
+func (sk *ScoreKeeper) add(ctx context.Context, scoreType, action, cohort string) (AddActionResult, error) {
+	if sk.s == nil {
+		return AddActionResult{}, ErrNoKeeper
+	}
+	if _, err := sk.channels(); err != nil {
+		return AddActionResult{}, err
 	}
 	if valid := ValidScoreType(sk, scoreType); !valid {
-		return score.ErrBadScoreType
+		return AddActionResult{}, score.ErrBadScoreType
 	}
 
+	s, err := sk.read(scoreType, action, cohort)
+	if err != nil {
+		return AddActionResult{}, err
+	}
+
+	results, rejected, err := sk.send(ctx, []score.Score{s})
+	if err != nil {
+		return AddActionResult{}, err
+	}
+	if rejected != nil {
+		return AddActionResult{}, rejected[0]
+	}
+
+	return results[0], nil
+}
+
+// read the action into a score of scoreType, tagged with cohort if there is one.
+func (sk *ScoreKeeper) read(scoreType, action, cohort string) (score.Score, error) {
 	s, err := score.Create(sk.f, scoreType)
 	if err != nil {
-		return fmt.Errorf("failed to AddAction of type %s: %w", scoreType, err)
+		return nil, fmt.Errorf("failed to AddAction of type %s: %w", scoreType, err)
 	}
 	if err := s.Read(action); err != nil {
-		return err
+		return nil, err
+	}
+	if cohort != "" {
+		m := score.MetadataOf(s)
+		if m == nil {
+			return nil, score.ErrNoMeta
+		}
+		m.Cohort = cohort
 	}
 
-	errCh := make(chan error)
-	sk.scores <- scoreEnvelope{
-		score: s,
-		err:   errCh,
+	return s, nil
+}
+
+// send scores to the worker and wait for them to be stored, returning whether each one stored set a record,
+// and the error for each one rejected as an outlier by index, or nil if none were.
+func (sk *ScoreKeeper) send(ctx context.Context, ss []score.Score) ([]AddActionResult, []error, error) {
+	w, err := sk.channels()
+	if err != nil {
+		return nil, nil, err
 	}
-	err = <-errCh
 
-	return err
+	// buffer the reply so the worker never blocks on a caller that gave up
+	addCh := make(chan addResult, 1)
+	select {
+	case w.scores <- scoreEnvelope{
+		ctx:    ctx,
+		scores: ss,
+		r:      addCh,
+	}:
+	case <-w.quit:
+		return nil, nil, ErrNotRunning
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	select {
+	case res := <-addCh:
+		return res.results, res.rejected, res.err
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
 }
 
 // GetStats computes some statistics about the actions stored in the ScoreKeeper.
-// It returns those statistics as a json-encoded string
-func (sk *ScoreKeeper) GetStats(scoreType string) (string, error) {
+// It returns those statistics as a json-encoded string, with one object per action in order of action name.
+// See Stats for the same statistics as Go values.
+// Name the stats to compute, like "avg", "median" or "p99"; the default is "avg".
+func (sk *ScoreKeeper) GetStats(scoreType string, stats ...string) (string, error) {
+	return sk.GetStatsContext(context.Background(), scoreType, stats...)
+}
+
+// GetStatsWith computes the named stats for each action, like GetStats.
+// Each action's json object has one key per stat, for example
+// `[{"action":"hop","avg":100,"count":3,"max":150}]`.
+// The names must be registered with the ScoreKeeper; see stat.Defaults and WithStats.
+func (sk *ScoreKeeper) GetStatsWith(scoreType string, statNames ...string) (string, error) {
+	return sk.GetStatsContext(context.Background(), scoreType, statNames...)
+}
+
+// GetStatsContext is GetStats with a context.
+// It gives up and returns ctx.Err() if the context is done before the stats are ready.
+func (sk *ScoreKeeper) GetStatsContext(ctx context.Context, scoreType string, stats ...string) (string, error) {
+	return sk.requestJSON(ctx, scoreType, store.Query{}, stats)
+}
+
+// GetStatsSorted computes the named stats for each action, like GetStatsWith,
+// with the actions in order o and only the first limit of them, or all of them if limit isn't positive.
+// It can feed a leaderboard directly, like the 10 fastest actions with
+// GetStatsSorted(scoreType, ByAvg, 10). The stat sorted by is always included.
+func (sk *ScoreKeeper) GetStatsSorted(scoreType string, o Order, limit int, statNames ...string) (string, error) {
+	if len(statNames) == 0 {
+		statNames = []string{"avg"}
+	}
+	if name := o.stat(); name != "" && !contains(statNames, name) {
+		statNames = append(statNames, name)
+	}
+
+	r, err := sk.request(context.Background(), scoreType, store.Query{}, statNames)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Sort(o).Top(limit).JSON()
+}
+
+// contains reports whether names includes name.
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats computes the named stats for each action, like GetStats,
+// returning them as a StatsReport ordered by action name instead of as json.
+// Reorder it with StatsReport.Sort and cut it short with StatsReport.Top.
+func (sk *ScoreKeeper) Stats(scoreType string, statNames ...string) (StatsReport, error) {
+	return sk.StatsContext(context.Background(), scoreType, statNames...)
+}
+
+// StatsContext is Stats with a context.
+// It gives up and returns ctx.Err() if the context is done before the stats are ready.
+func (sk *ScoreKeeper) StatsContext(ctx context.Context, scoreType string, statNames ...string) (StatsReport, error) {
+	return sk.request(ctx, scoreType, store.Query{}, statNames)
+}
+
+// GetCohortStats computes the named stats for each action, like GetStatsWith,
+// over only the scores in the given cohorts. With no cohorts, it covers every score.
+func (sk *ScoreKeeper) GetCohortStats(scoreType string, cohorts []string, statNames ...string) (string, error) {
+	return sk.requestJSON(context.Background(), scoreType, store.Query{Cohorts: cohorts}, statNames)
+}
+
+// GetStatsBetween computes the named stats for each action, like GetStatsWith,
+// over only the scores for actions that happened from `from` until just before `to`.
+// A score happened at its "at" time if the client gave one, otherwise when it was recorded.
+// A zero from or to leaves that end of the range open.
+func (sk *ScoreKeeper) GetStatsBetween(scoreType string, from, to time.Time, statNames ...string) (string, error) {
+	return sk.requestJSON(context.Background(), scoreType, store.Query{Since: from, Until: to}, statNames)
+}
+
+// requestJSON requests the named stats for the scores matching q, encoded as json.
+func (sk *ScoreKeeper) requestJSON(ctx context.Context, scoreType string, q store.Query, stats []string) (string, error) {
+	r, err := sk.request(ctx, scoreType, q, stats)
+	if err != nil {
+		return "", err
+	}
+
+	return r.JSON()
+}
+
+// request the named stats for the scores matching q from the worker.
+func (sk *ScoreKeeper) request(ctx context.Context, scoreType string, q store.Query, stats []string) (StatsReport, error) {
+	return sk.requestFor(ctx, scoreType, q, stats, nil)
+}
+
+// requestFor is request, also having the worker subscribe sub along with the report if it is set.
+func (sk *ScoreKeeper) requestFor(ctx context.Context, scoreType string, q store.Query, stats []string, sub *subscriber) (StatsReport, error) {
 	if sk.s == nil {
-		return "", ErrNoKeeper
+		return StatsReport{}, ErrNoKeeper
 	}
-	if sk.scores == nil || sk.quit == nil {
-		return "", ErrNotRunning
+	w, err := sk.channels()
+	if err != nil {
+		return StatsReport{}, err
 	}
 	if valid := ValidScoreType(sk, scoreType); !valid {
-		return "", score.ErrBadScoreType
+		return StatsReport{}, score.ErrBadScoreType
+	}
+	if len(stats) == 0 {
+		stats = []string{"avg"}
+	}
+	for _, name := range stats {
+		if _, err := stat.Create(sk.stats, name); err != nil {
+			return StatsReport{}, fmt.Errorf("%w: %s", err, name)
+		}
 	}
 
 	// pass a channel to the worker and wait for it to return the result
-	requestCh := make(chan result)
-	sk.requests <- requestEnvelope{
+	requestCh := make(chan result, 1)
+	select {
+	case w.requests <- requestEnvelope{
+		ctx:       ctx,
 		scoreType: scoreType,
+		query:     q,
+		stats:     stats,
+		sub:       sub,
 		r:         requestCh,
+	}:
+	case <-w.quit:
+		return StatsReport{}, ErrNotRunning
+	case <-ctx.Done():
+		return StatsReport{}, ctx.Err()
 	}
-	res := <-requestCh
 
-	return res.result, res.err
+	select {
+	case res := <-requestCh:
+		return res.report, res.err
+	case <-ctx.Done():
+		return StatsReport{}, ctx.Err()
+	}
 }
 
-// get scores from the store and compute averages, returning a json-encoded string
-func (sk *ScoreKeeper) get(scoreType string) (string, error) {
+// get the named stats for the scores matching q, returning them by action.
+// Stats over every score are kept running; stats that aren't running yet,
+// like an unregistered percentile, are caught up from the store first.
+// Stats over some of the scores, like a cohort, are computed from the store.
+func (sk *ScoreKeeper) get(ctx context.Context, scoreType string, q store.Query, stats []string) (StatsReport, error) {
 	if sk.s == nil {
-		return "", store.ErrNoStore
+		return StatsReport{}, store.ErrNoStore
 	}
 
-	scoreMap, err := sk.s.Retrieve(sk.f, scoreType)
-	if err != nil {
-		return "", err
+	aggs := sk.aggs
+	if !q.All() {
+		aggs = newAggregates(sk.stats, sk.now)
 	}
 
-	if len(scoreMap) == 0 {
-		return "", stat.ErrNoData
+	if err := sk.catchUp(ctx, aggs, scoreType, q, stats); err != nil {
+		return StatsReport{}, err
 	}
 
-	avgs := make([]score.AverageTime, 0, len(scoreMap))
-
-	for name, scores := range scoreMap {
-		a := stat.Average{}
-
-		res, err := a.Compute(scores)
-		if err != nil {
-			return "", err
-		}
-
-		avg, ok := res.(float64)
-		if !ok {
-			return "", stat.ErrTypeInvalid
-		}
+	return aggs.report(scoreType, stats)
+}
 
-		avgs = append(avgs, score.AverageTime{
-			Action:  name,
-			Average: avg,
-		})
+// catchUp starts running the named stats that aggs doesn't have yet for scoreType,
+// over the scores in the store matching q.
+func (sk *ScoreKeeper) catchUp(ctx context.Context, aggs *aggregates, scoreType string, q store.Query, stats []string) error {
+	missing := aggs.untracked(scoreType, stats)
+	if len(missing) == 0 {
+		return nil
 	}
 
-	b, err := json.Marshal(avgs)
-	if err != nil {
-		return "", err
+	scoreMap, err := sk.s.Retrieve(ctx, sk.f, scoreType, q)
+	if err != nil && !errors.Is(err, store.ErrNoScores) {
+		return err
 	}
 
-	return string(b), nil
+	return aggs.track(scoreType, missing, scoreMap)
 }
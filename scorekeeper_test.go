@@ -1,9 +1,13 @@
 package scorekeeper
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/bdharris08/scorekeeper/score"
 	"github.com/bdharris08/scorekeeper/stat"
@@ -64,10 +68,37 @@ func TestAddActionErrors(t *testing.T) {
 			action: `{}`,
 			err:    score.ErrNoTime,
 		},
+		{
+			name:   "cohort",
+			action: `{"action":"jump", "time":1, "cohort":"run-1"}`,
+		},
+		{
+			name:   "bad cohort",
+			action: `{"action":"jump", "time":1, "cohort":1}`,
+			err:    score.ErrBadCohort,
+		},
+		{
+			name:   "at",
+			action: `{"action":"jump", "time":1, "at":"2022-03-04T05:06:07Z"}`,
+		},
+		{
+			name:   "null at",
+			action: `{"action":"jump", "time":1, "at":null}`,
+		},
+		{
+			name:   "bad at",
+			action: `{"action":"jump", "time":1, "at":"yesterday"}`,
+			err:    score.ErrBadAt,
+		},
+		{
+			name:   "numeric at",
+			action: `{"action":"jump", "time":1, "at":1646370367}`,
+			err:    score.ErrBadAt,
+		},
 	}
 
 	for _, tc := range testCases {
-		store := &store.MemoryStore{S: map[string]map[string][]score.Score{}}
+		store := store.NewMemoryStore()
 		factory := score.ScoreFactory{
 			scoreType: func() score.Score { return &score.Trial{} },
 		}
@@ -153,7 +184,7 @@ func TestGetStats(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		store := &store.MemoryStore{S: map[string]map[string][]score.Score{}}
+		store := store.NewMemoryStore()
 		factory := score.ScoreFactory{
 			scoreType: func() score.Score { return &score.Trial{} },
 		}
@@ -269,7 +300,7 @@ func TestStatsEquivalent(t *testing.T) {
 
 func TestConcurrent(t *testing.T) {
 	scoreType := "trial"
-	store := &store.MemoryStore{S: map[string]map[string][]score.Score{}}
+	store := store.NewMemoryStore()
 	factory := score.ScoreFactory{
 		scoreType: func() score.Score { return &score.Trial{} },
 	}
@@ -329,7 +360,7 @@ func TestConcurrent(t *testing.T) {
 }
 
 func TestValidScoreType(t *testing.T) {
-	store := &store.MemoryStore{S: map[string]map[string][]score.Score{}}
+	store := store.NewMemoryStore()
 	factory := score.ScoreFactory{
 		"trial": nil,
 		"test":  nil,
@@ -367,3 +398,894 @@ func TestValidScoreType(t *testing.T) {
 		}
 	}
 }
+
+// stuckStore never finishes storing until the caller gives up.
+type stuckStore struct {
+	entered chan struct{}
+}
+
+func (st stuckStore) Store(ctx context.Context, s score.Score) error {
+	st.entered <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (stuckStore) Retrieve(ctx context.Context, f score.ScoreFactory, scoreType string, q store.Query) (map[string][]score.Score, error) {
+	return nil, nil
+}
+
+func TestContextDeadline(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	st := stuckStore{entered: make(chan struct{}, 2)}
+	s, err := New(st, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = s.AddActionContext(ctx, scoreType, `{"action":"jump", "time":100}`)
+	if expected, got := context.DeadlineExceeded, err; expected != got {
+		t.Errorf("Expected AddActionContext error to be '%v' but got '%v'", expected, got)
+	}
+
+	// keep the worker stuck so the request can't be taken
+	busyCtx, busyCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer busyCancel()
+	busy := make(chan error, 1)
+	go func() {
+		busy <- s.AddActionContext(busyCtx, scoreType, `{"action":"jump", "time":100}`)
+	}()
+	<-st.entered
+	<-st.entered
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = s.GetStatsContext(ctx, scoreType)
+	if expected, got := context.DeadlineExceeded, err; expected != got {
+		t.Errorf("Expected GetStatsContext error to be '%v' but got '%v'", expected, got)
+	}
+
+	<-busy
+}
+
+func TestContextCanceled(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	s, err := New(nil, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = s.AddActionContext(ctx, scoreType, `{"action":"jump", "time":100}`)
+	if expected, got := context.Canceled, err; expected != got {
+		t.Errorf("Expected AddActionContext error to be '%v' but got '%v'", expected, got)
+	}
+}
+
+// gateStore holds every Store until release is closed.
+type gateStore struct {
+	store.MemoryStore
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g *gateStore) Store(ctx context.Context, s score.Score) error {
+	g.entered <- struct{}{}
+	<-g.release
+	return g.MemoryStore.Store(ctx, s)
+}
+
+func TestStopDrains(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	gs := &gateStore{
+		entered: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	s, err := New(gs, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start()
+
+	// the first action is in flight, held inside the store
+	inFlight := make(chan error, 1)
+	go func() {
+		inFlight <- s.AddAction(scoreType, `{"action":"jump", "time":100}`)
+	}()
+	<-gs.entered
+
+	// the second action is blocked waiting for the busy worker
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- s.AddAction(scoreType, `{"action":"jump", "time":200}`)
+	}()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Stop()
+	}()
+
+	if expected, got := ErrNotRunning, <-blocked; expected != got {
+		t.Errorf("Expected blocked AddAction error to be '%v' but got '%v'", expected, got)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned before the in-flight score was stored")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(gs.release)
+
+	if expected, got := error(nil), <-inFlight; expected != got {
+		t.Errorf("Expected in-flight AddAction error to be '%v' but got '%v'", expected, got)
+	}
+	if expected, got := error(nil), <-stopped; expected != got {
+		t.Errorf("Expected Stop error to be '%v' but got '%v'", expected, got)
+	}
+
+	scores, err := gs.MemoryStore.Retrieve(context.Background(), factory, scoreType, store.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := 1, len(scores["jump"]); expected != got {
+		t.Errorf("Expected %d stored scores but got %d", expected, got)
+	}
+}
+
+func TestStartWhileStopping(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	gs := &gateStore{
+		entered: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	s, err := New(gs, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start()
+	// keep avg running, so the in-flight score is stepped into it
+	if _, err := s.GetStats(scoreType); err != stat.ErrNoData {
+		t.Fatalf("Expected error to be '%v' but got '%v'", stat.ErrNoData, err)
+	}
+
+	inFlight := make(chan error, 1)
+	go func() {
+		inFlight <- s.AddAction(scoreType, `{"action":"jump", "time":100}`)
+	}()
+	<-gs.entered
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Stop()
+	}()
+	for {
+		if _, err := s.channels(); err == ErrNotRunning {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Start waits for the stopping worker before rebuilding what it steps into
+	started := make(chan error, 1)
+	go func() {
+		started <- s.Start()
+	}()
+	select {
+	case <-started:
+		t.Fatal("Start returned before the stopping worker exited")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(gs.release)
+	for name, ch := range map[string]chan error{"in-flight AddAction": inFlight, "Stop": stopped, "Start": started} {
+		if err := <-ch; err != nil {
+			t.Errorf("Expected %s error to be '<nil>' but got '%v'", name, err)
+		}
+	}
+	defer s.Stop()
+
+	res, err := s.GetStats(scoreType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"jump","avg":100}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	s, err := New(nil, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, got := ErrNotRunning, s.AddAction(scoreType, `{"action":"jump", "time":100}`); expected != got {
+		t.Errorf("Expected AddAction before Start to return '%v' but got '%v'", expected, got)
+	}
+	if expected, got := ErrNotRunning, s.Stop(); expected != got {
+		t.Errorf("Expected Stop before Start to return '%v' but got '%v'", expected, got)
+	}
+
+	s.Start()
+	s.Start()
+	if err := s.AddAction(scoreType, `{"action":"jump", "time":100}`); err != nil {
+		t.Error(err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	if expected, got := ErrNotRunning, s.AddAction(scoreType, `{"action":"jump", "time":100}`); expected != got {
+		t.Errorf("Expected AddAction after Stop to return '%v' but got '%v'", expected, got)
+	}
+	if _, err := s.GetStats(scoreType); err != ErrNotRunning {
+		t.Errorf("Expected GetStats after Stop to return '%v' but got '%v'", ErrNotRunning, err)
+	}
+	if expected, got := ErrNotRunning, s.Stop(); expected != got {
+		t.Errorf("Expected second Stop to return '%v' but got '%v'", expected, got)
+	}
+
+	// a stopped keeper can be started again and keeps its store
+	s.Start()
+	defer s.Stop()
+
+	res, err := s.GetStats(scoreType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"jump","avg":100}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestGetStatsPercentiles(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	s, err := New(nil, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	for i := 1; i <= 101; i++ {
+		if err := s.AddAction(scoreType, fmt.Sprintf(`{"action":"jump", "time":%d}`, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := s.GetStats(scoreType, "avg", "median", "p50", "p90", "p99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"jump","avg":51,"median":51,"p50":51,"p90":91,"p99":100}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+
+	if _, err := s.GetStats(scoreType, "p200"); !errors.Is(err, stat.ErrUnknownStat) {
+		t.Errorf("Expected error to be '%v' but got '%v'", stat.ErrUnknownStat, err)
+	}
+}
+
+func TestGetStatsWith(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	s, err := New(nil, factory, WithStats(stat.Factory{
+		"fastest": func() stat.Stat { return &stat.Min{} },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	for _, a := range []string{
+		`{"action":"jump", "time":100}`,
+		`{"action":"jump", "time":200}`,
+		`{"action":"jump", "time":300}`,
+	} {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := s.GetStatsWith(scoreType, "avg", "min", "max", "count", "median", "fastest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `[{"action":"jump","avg":200,"count":3,"fastest":100,"max":300,"median":200,"min":100}]`
+	if expected, got := e, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+
+	if _, err := s.GetStatsWith(scoreType, "slowest"); !errors.Is(err, stat.ErrUnknownStat) {
+		t.Errorf("Expected error to be '%v' but got '%v'", stat.ErrUnknownStat, err)
+	}
+}
+
+func TestCohorts(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	s, err := New(nil, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	for _, a := range []struct{ cohort, action string }{
+		{"run-1", `{"action":"jump", "time":100}`},
+		{"run-1", `{"action":"hop", "time":10}`},
+		{"run-2", `{"action":"jump", "time":200}`},
+		{"run-3", `{"action":"jump", "time":600}`},
+		{"", `{"action":"jump", "time":300, "cohort":"run-2"}`},
+		{"run-3", `{"action":"jump", "time":0, "cohort":"run-2"}`},
+	} {
+		if err := s.AddCohortAction(scoreType, a.cohort, a.action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type testCase struct {
+		name    string
+		cohorts []string
+		stats   string
+		err     error
+	}
+	testCases := []testCase{
+		{
+			name:    "one",
+			cohorts: []string{"run-1"},
+			stats:   `[{"action":"hop","avg":10},{"action":"jump","avg":100}]`,
+		},
+		{
+			name:    "from json",
+			cohorts: []string{"run-2"},
+			stats:   `[{"action":"jump","avg":250}]`,
+		},
+		{
+			name:    "several",
+			cohorts: []string{"run-2", "run-3"},
+			stats:   `[{"action":"jump","avg":275}]`,
+		},
+		{
+			name:  "all",
+			stats: `[{"action":"hop","avg":10},{"action":"jump","avg":240}]`,
+		},
+		{
+			name:    "none",
+			cohorts: []string{"run-4"},
+			err:     stat.ErrNoData,
+		},
+	}
+
+	for _, tc := range testCases {
+		res, err := s.GetCohortStats(scoreType, tc.cohorts)
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := tc.stats, res; !statsEquivalent(expected, got) {
+			t.Errorf("[%s] Expected '%s' but got '%s'", tc.name, expected, got)
+		}
+	}
+
+	// cohort stats don't disturb the running stats
+	res, err := s.GetStats(scoreType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"hop","avg":10},{"action":"jump","avg":240}]`, res; !statsEquivalent(expected, got) {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestGetStatsBetween(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	// each score is recorded an hour after the last
+	base := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	clock := base
+	now := func() time.Time {
+		clock = clock.Add(time.Hour)
+		return clock
+	}
+
+	st := &store.MemoryStore{}
+	s, err := New(st, factory, WithClock(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	for _, a := range []string{
+		`{"action":"jump", "time":100}`,
+		`{"action":"jump", "time":200}`,
+		`{"action":"jump", "time":300}`,
+		`{"action":"jump", "time":1000, "at":"2022-03-03T12:00:00Z"}`,
+	} {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scores, err := st.Retrieve(context.Background(), factory, scoreType, store.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := base.Add(time.Hour), score.MetadataOf(scores["jump"][0]).Recorded; !expected.Equal(got) {
+		t.Errorf("Expected the score to be recorded at %v but got %v", expected, got)
+	}
+
+	type testCase struct {
+		name     string
+		from, to time.Time
+		stats    string
+		err      error
+	}
+	testCases := []testCase{
+		{
+			name:  "all",
+			stats: `[{"action":"jump","avg":400}]`,
+		},
+		{
+			name:  "from",
+			from:  base.Add(2 * time.Hour),
+			stats: `[{"action":"jump","avg":250}]`,
+		},
+		{
+			name:  "to",
+			to:    base.Add(2 * time.Hour),
+			stats: `[{"action":"jump","avg":550}]`,
+		},
+		{
+			name:  "between",
+			from:  base.Add(time.Hour),
+			to:    base.Add(3 * time.Hour),
+			stats: `[{"action":"jump","avg":150}]`,
+		},
+		{
+			name: "nothing",
+			from: base.Add(10 * time.Hour),
+			err:  stat.ErrNoData,
+		},
+	}
+
+	for _, tc := range testCases {
+		res, err := s.GetStatsBetween(scoreType, tc.from, tc.to)
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := tc.stats, res; expected != got {
+			t.Errorf("[%s] Expected '%s' but got '%s'", tc.name, expected, got)
+		}
+	}
+}
+
+func TestGetStatsWindows(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	// the clock only moves when the test moves it
+	var mu sync.Mutex
+	clock := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+	wait := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		clock = clock.Add(d)
+	}
+
+	s, err := New(&store.MemoryStore{}, factory, WithClock(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	for _, a := range []string{
+		`{"action":"jump", "time":100}`,
+		`{"action":"jump", "time":200}`,
+		`{"action":"jump", "time":300}`,
+	} {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatal(err)
+		}
+		wait(2 * time.Minute)
+	}
+
+	// scores at 0m, 2m and 4m, and it's 6m now
+	res, err := s.GetStats(scoreType, "avg", "avg_last2", "avg_3m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"jump","avg":200,"avg_3m":300,"avg_last2":250}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+
+	// every score has left the time window, but the action is still reported
+	wait(time.Hour)
+	res, err = s.GetStats(scoreType, "avg", "avg_last2", "avg_3m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"jump","avg":200,"avg_3m":null,"avg_last2":250}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestAddActionFuture(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+	clock := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	testCases := []struct {
+		name    string
+		opts    []Option
+		at      time.Time
+		wantErr error
+	}{
+		{name: "past", at: clock.Add(-time.Hour)},
+		{name: "within the default skew", at: clock.Add(DefaultMaxSkew)},
+		{name: "beyond the default skew", at: clock.Add(DefaultMaxSkew + time.Second), wantErr: ErrFutureAt},
+		{name: "an hour ahead", at: clock.Add(time.Hour), wantErr: ErrFutureAt},
+		{name: "within a wider skew", opts: []Option{WithMaxSkew(2 * time.Hour)}, at: clock.Add(time.Hour)},
+		{name: "beyond no skew", opts: []Option{WithMaxSkew(0)}, at: clock.Add(time.Second), wantErr: ErrFutureAt},
+	}
+
+	for _, tc := range testCases {
+		s, err := New(&store.MemoryStore{}, factory, append([]Option{WithClock(now)}, tc.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		action := fmt.Sprintf(`{"action":"jump", "time":100, "at":"%s"}`, tc.at.Format(time.RFC3339))
+		if expected, got := tc.wantErr, s.AddAction(scoreType, action); !errors.Is(got, expected) {
+			t.Errorf("[%s] Expected error '%v' but got '%v'", tc.name, expected, got)
+		}
+
+		// the rest of a batch is kept
+		errs, err := s.AddActions(scoreType, []string{action, `{"action":"jump", "time":200}`})
+		if err != nil {
+			t.Fatalf("[%s] %v", tc.name, err)
+		}
+		if tc.wantErr != nil {
+			if expected, got := tc.wantErr, errs[0]; !errors.Is(got, expected) {
+				t.Errorf("[%s] Expected error '%v' but got '%v'", tc.name, expected, got)
+			}
+		} else if errs != nil {
+			t.Errorf("[%s] Expected no errors but got %v", tc.name, errs)
+		}
+
+		res, err := s.GetStats(scoreType, "count")
+		if err != nil {
+			t.Fatalf("[%s] %v", tc.name, err)
+		}
+		count := 3
+		if tc.wantErr != nil {
+			count = 1
+		}
+		if expected, got := fmt.Sprintf(`[{"action":"jump","count":%d}]`, count), res; expected != got {
+			t.Errorf("[%s] Expected '%s' but got '%s'", tc.name, expected, got)
+		}
+
+		s.Stop()
+	}
+}
+
+// batchingStore counts the batches stored.
+type batchingStore struct {
+	store.MemoryStore
+	batches int
+}
+
+func (b *batchingStore) StoreBatch(ctx context.Context, ss []score.Score) error {
+	b.batches++
+	return b.MemoryStore.StoreBatch(ctx, ss)
+}
+
+// plainStore hides any StoreBatch of the store it wraps.
+type plainStore struct {
+	store.ScoreStore
+}
+
+func TestAddActions(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	batching := &batchingStore{}
+	for _, st := range []store.ScoreStore{batching, plainStore{&store.MemoryStore{}}} {
+		s, err := New(st, factory)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.AddActions(scoreType, []string{`{"action":"jump", "time":100}`}); err != ErrNotRunning {
+			t.Errorf("Expected error to be '%v' but got '%v'", ErrNotRunning, err)
+		}
+
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		errs, err := s.AddActions(scoreType, []string{
+			`{"action":"jump", "time":100}`,
+			`{"action":"jump", "time":"fast"}`,
+			`{"action":"jump", "time":200}`,
+			``,
+			`{"action":"hop", "time":50}`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedErrs := []error{nil, score.ErrBadTime, nil, score.ErrNoInput, nil}
+		if expected, got := len(expectedErrs), len(errs); expected != got {
+			t.Fatalf("Expected %d errors but got %d", expected, got)
+		}
+		for i := range expectedErrs {
+			if expected, got := expectedErrs[i], errs[i]; expected != got {
+				t.Errorf("[%d] Expected error to be '%v' but got '%v'", i, expected, got)
+			}
+		}
+
+		errs, err = s.AddActions(scoreType, []string{`{"action":"hop", "time":150}`, `{"action":"hop", "time":100}`})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs != nil {
+			t.Errorf("Expected no errors but got %v", errs)
+		}
+
+		res, err := s.GetStats(scoreType, "avg", "count")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := `[{"action":"hop","avg":100,"count":3},{"action":"jump","avg":150,"count":2}]`, res; !statsEquivalent(expected, got) {
+			t.Errorf("Expected '%s' but got '%s'", expected, got)
+		}
+
+		if _, err := s.AddActions("race", []string{`{"action":"jump", "time":100}`}); err != score.ErrBadScoreType {
+			t.Errorf("Expected error to be '%v' but got '%v'", score.ErrBadScoreType, err)
+		}
+
+		s.Stop()
+	}
+
+	if expected, got := 2, batching.batches; expected != got {
+		t.Errorf("Expected %d batches but got %d", expected, got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	s, err := New(&store.MemoryStore{}, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if _, err := s.Stats(scoreType); err != stat.ErrNoData {
+		t.Errorf("Expected error to be '%v' but got '%v'", stat.ErrNoData, err)
+	}
+
+	for _, a := range []string{
+		`{"action":"skip", "time":30}`,
+		`{"action":"jump", "time":100}`,
+		`{"action":"hop", "time":10}`,
+		`{"action":"jump", "time":200}`,
+		`{"action":"hop", "time":20}`,
+		`{"action":"jump", "time":300}`,
+	} {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := s.Stats(scoreType, "avg", "max")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := scoreType, r.ScoreType; expected != got {
+		t.Errorf("Expected scoreType %s but got %s", expected, got)
+	}
+
+	type row struct {
+		action string
+		count  int
+		avg    float64
+		max    float64
+	}
+	expected := []row{
+		{action: "hop", count: 2, avg: 15, max: 20},
+		{action: "jump", count: 3, avg: 200, max: 300},
+		{action: "skip", count: 1, avg: 30, max: 30},
+	}
+	if e, g := len(expected), len(r.Actions); e != g {
+		t.Fatalf("Expected %d actions but got %d", e, g)
+	}
+	for i, e := range expected {
+		a := r.Actions[i]
+		if expected, got := e.action, a.Action; expected != got {
+			t.Errorf("[%d] Expected action %s but got %s", i, expected, got)
+		}
+		if expected, got := e.count, a.Count; expected != got {
+			t.Errorf("[%s] Expected count %d but got %d", e.action, expected, got)
+		}
+		if avg, ok := a.Float("avg"); !ok || avg != e.avg {
+			t.Errorf("[%s] Expected avg %f but got %v", e.action, e.avg, a.Values["avg"])
+		}
+		if max, ok := a.Float("max"); !ok || max != e.max {
+			t.Errorf("[%s] Expected max %f but got %v", e.action, e.max, a.Values["max"])
+		}
+	}
+
+	// GetStats is the same report, as json in the same order
+	res, err := s.GetStats(scoreType, "avg", "max")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `[{"action":"hop","avg":15,"max":20},{"action":"jump","avg":200,"max":300},{"action":"skip","avg":30,"max":30}]`, res; expected != got {
+		t.Errorf("Expected '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestGetStatsSorted(t *testing.T) {
+	scoreType := "trial"
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.Trial{} },
+	}
+
+	s, err := New(&store.MemoryStore{}, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	for _, a := range []string{
+		`{"action":"skip", "time":30}`,
+		`{"action":"jump", "time":100}`,
+		`{"action":"hop", "time":10}`,
+		`{"action":"jump", "time":200}`,
+		`{"action":"hop", "time":20}`,
+		`{"action":"jump", "time":300}`,
+	} {
+		if err := s.AddAction(scoreType, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type testCase struct {
+		name  string
+		order Order
+		limit int
+		stats []string
+		res   string
+		err   error
+	}
+
+	testCases := []testCase{
+		{
+			name:  "by action",
+			order: ByAction,
+			res:   `[{"action":"hop","avg":15},{"action":"jump","avg":200},{"action":"skip","avg":30}]`,
+		},
+		{
+			name:  "fastest",
+			order: ByAvg,
+			limit: 2,
+			res:   `[{"action":"hop","avg":15},{"action":"skip","avg":30}]`,
+		},
+		{
+			name:  "slowest",
+			order: ByAvgDesc,
+			limit: 1,
+			res:   `[{"action":"jump","avg":200}]`,
+		},
+		{
+			name:  "most played",
+			order: ByCount,
+			stats: []string{"count"},
+			res:   `[{"action":"jump","count":3},{"action":"hop","count":2},{"action":"skip","count":1}]`,
+		},
+		{
+			name:  "sorted stat included",
+			order: Order{By: "max"},
+			limit: 1,
+			stats: []string{"min"},
+			res:   `[{"action":"hop","max":20,"min":10}]`,
+		},
+		{
+			name:  "unknown stat",
+			order: Order{By: "mode"},
+			err:   stat.ErrUnknownStat,
+		},
+	}
+
+	for _, tc := range testCases {
+		res, err := s.GetStatsSorted(scoreType, tc.order, tc.limit, tc.stats...)
+		if expected, got := tc.err, err; !errors.Is(got, expected) {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := tc.res, res; expected != got {
+			t.Errorf("[%s] Expected '%s' but got '%s'", tc.name, expected, got)
+		}
+	}
+}
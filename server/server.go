@@ -0,0 +1,326 @@
+// Package server serves a ScoreKeeper over HTTP, with json requests and responses.
+//
+// The routes are:
+//
+//	POST /v1/{scoreType}/actions  keeps the json action in the body, like AddAction
+//	GET  /v1/{scoreType}/stats    reports stats, like GetStats. Name them with ?stat=avg&stat=p99,
+//	                              sort them with ?order=-avg and keep the top few with ?limit=10
+//	GET  /v1/{scoreType}/stream   streams the stats as server-sent events, like Subscribe.
+//	                              Each "stats" event is sent as scores are added, naming stats like /stats
+//	GET  /v1/{scoreType}/metrics  renders histogram stats in the Prometheus text format, like StatsReport.Prometheus.
+//	                              Name them with ?stat=, "histogram" by default
+//
+// Errors are returned as a json object like {"error":"invalid time"}, with a matching status code.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bdharris08/scorekeeper"
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+// maxActionSize limits the body of a posted action.
+const maxActionSize = 1 << 20
+
+// statusClientClosedRequest is the nonstandard status for a request the client gave up on, as nginx logs it.
+const statusClientClosedRequest = 499
+
+var ErrTooLarge = errors.New("request body too large")
+
+// Handler is an http.Handler for a ScoreKeeper.
+type Handler struct {
+	sk *scorekeeper.ScoreKeeper
+
+	// closing is closed by CloseStreams to end every stream
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a Handler serving sk, which must be started to take requests.
+func New(sk *scorekeeper.ScoreKeeper) *Handler {
+	return &Handler{sk: sk, closing: make(chan struct{})}
+}
+
+// CloseStreams ends the streams being served, and any started after, which otherwise last until their clients leave.
+// Register it with http.Server.RegisterOnShutdown so Shutdown doesn't wait on them.
+func (h *Handler) CloseStreams() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// ServeHTTP routes a request to its handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// "/v1/{scoreType}/{resource}"
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "v1" || parts[1] == "" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	scoreType, resource := parts[1], parts[2]
+
+	switch resource {
+	case "actions":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		h.addAction(w, r, scoreType)
+	case "stats":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, http.MethodGet, http.MethodHead)
+			return
+		}
+		h.getStats(w, r, scoreType)
+	case "stream":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		h.stream(w, r, scoreType)
+	case "metrics":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, http.MethodGet, http.MethodHead)
+			return
+		}
+		h.metrics(w, r, scoreType)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// addAction keeps the action in the request body.
+func (h *Handler) addAction(w http.ResponseWriter, r *http.Request, scoreType string) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxActionSize))
+	if err != nil {
+		// http.MaxBytesReader doesn't export its error, so it is told apart by its text
+		if err.Error() == "http: request body too large" {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrTooLarge)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.sk.AddActionContext(r.Context(), scoreType, string(body)); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getStats reports the stats named by the "stat" query parameters, which may also be comma separated.
+// The actions are sorted by the "order" parameter, like "avg" or "-avg" for descending,
+// and limited to the first "limit" of them.
+func (h *Handler) getStats(w http.ResponseWriter, r *http.Request, scoreType string) {
+	names := statNames(r, "avg")
+
+	order := scorekeeper.ByAction
+	if o := r.URL.Query().Get("order"); strings.TrimPrefix(o, "-") != "" {
+		order = scorekeeper.Order{By: strings.TrimPrefix(o, "-"), Desc: strings.HasPrefix(o, "-")}
+	}
+	if by := order.By; by != "action" && by != "count" && !contains(names, by) {
+		// the stat sorted by is always included
+		names = append(names, by)
+	}
+
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", l))
+			return
+		}
+		limit = n
+	}
+
+	report, err := h.sk.StatsContext(r.Context(), scoreType, names...)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	res, err := report.Sort(order).Top(limit).JSON()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(res))
+}
+
+// streamEvent is the json data of a stats event on a stream.
+type streamEvent struct {
+	Actions []string        `json:"actions,omitempty"`
+	Missed  int             `json:"missed,omitempty"`
+	Stats   json.RawMessage `json:"stats"`
+}
+
+// stream sends the stats named by the "stat" query parameters as server-sent events,
+// first as they are and then each time scores are added, until the client goes away or CloseStreams is called.
+// A client too slow to keep up gets the latest stats, with the count of updates it missed.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, scoreType string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+
+	// the subscription ends when the client goes away or the streams are closed
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	updates, err := h.sk.Subscribe(ctx, scoreType,
+		scorekeeper.WithPolicy(scorekeeper.Coalesce),
+		scorekeeper.WithUpdateStats(statNames(r, "avg")...))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for u := range updates {
+		stats, err := u.Report.JSON()
+		if err != nil {
+			return
+		}
+
+		data, err := json.Marshal(streamEvent{Actions: u.Actions, Missed: u.Missed, Stats: json.RawMessage(stats)})
+		if err != nil {
+			return
+		}
+
+		if _, err := fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// metrics renders the histogram stats named by the "stat" query parameters for Prometheus to scrape.
+func (h *Handler) metrics(w http.ResponseWriter, r *http.Request, scoreType string) {
+	names := statNames(r, "histogram")
+
+	report, err := h.sk.StatsContext(r.Context(), scoreType, names...)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	var res strings.Builder
+	for _, name := range names {
+		prom, err := report.Prometheus(name)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		res.WriteString(prom)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(res.String()))
+}
+
+// statNames are the stats named by the "stat" query parameters, which may also be comma separated.
+// With none named it is just def.
+func statNames(r *http.Request, def string) []string {
+	var names []string
+	for _, param := range r.URL.Query()["stat"] {
+		for _, name := range strings.Split(param, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return []string{def}
+	}
+	return names
+}
+
+// contains reports whether names includes name.
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// statusOf maps an error from the ScoreKeeper to an http status code.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, score.ErrBadScoreType):
+		return http.StatusNotFound
+	case errors.Is(err, stat.ErrNoData), errors.Is(err, store.ErrNoScores):
+		return http.StatusNotFound
+	case errors.Is(err, score.ErrNoInput),
+		errors.Is(err, score.ErrBadInput),
+		errors.Is(err, score.ErrBadTime),
+		errors.Is(err, score.ErrNoTime),
+		errors.Is(err, score.ErrBadAction),
+		errors.Is(err, score.ErrBadCohort),
+		errors.Is(err, score.ErrBadPlayer),
+		errors.Is(err, score.ErrBadAt),
+		errors.Is(err, scorekeeper.ErrFutureAt),
+		errors.Is(err, stat.ErrUnknownStat),
+		errors.Is(err, scorekeeper.ErrNotHistogram),
+		errors.Is(err, scorekeeper.ErrBadSubscription),
+		errors.Is(err, store.ErrInvalidIdentifier):
+		return http.StatusBadRequest
+	case errors.Is(err, scorekeeper.ErrOutlier):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, scorekeeper.ErrNotRunning):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorBody is the json body of an error response.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// writeError writes err as a json error body with the status code.
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(errorBody{Error: err.Error()})
+}
+
+// methodNotAllowed responds to a request with a method the route doesn't take.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
@@ -0,0 +1,438 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bdharris08/scorekeeper"
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *scorekeeper.ScoreKeeper) {
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	sk, err := scorekeeper.New(&store.MemoryStore{}, factory, scorekeeper.WithStats(stat.Factory{
+		"time": func() stat.Stat { return &stat.Histogram{Bounds: []float64{100}} },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sk.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(New(sk))
+	t.Cleanup(func() {
+		srv.Close()
+		sk.Stop()
+	})
+
+	return srv, sk
+}
+
+// do sends a request to srv, returning the status code and body.
+func do(t *testing.T, srv *httptest.Server, method, path, body string) (int, string) {
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, strings.TrimSpace(string(b))
+}
+
+func TestServer(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	type testCase struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+		res    string
+	}
+
+	// cases run in order, against the same keeper
+	testCases := []testCase{
+		{
+			name:   "no data",
+			method: http.MethodGet,
+			path:   "/v1/trial/stats",
+			code:   http.StatusNotFound,
+			res:    `{"error":"no data to report"}`,
+		},
+		{
+			name:   "add",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			body:   `{"action":"jump", "time":100}`,
+			code:   http.StatusNoContent,
+		},
+		{
+			name:   "add another",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			body:   `{"action":"jump", "time":200}`,
+			code:   http.StatusNoContent,
+		},
+		{
+			name:   "stats",
+			method: http.MethodGet,
+			path:   "/v1/trial/stats",
+			code:   http.StatusOK,
+			res:    `[{"action":"jump","avg":150}]`,
+		},
+		{
+			name:   "named stats",
+			method: http.MethodGet,
+			path:   "/v1/trial/stats?stat=avg&stat=max,count",
+			code:   http.StatusOK,
+			res:    `[{"action":"jump","avg":150,"count":2,"max":200}]`,
+		},
+		{
+			name:   "unknown stat",
+			method: http.MethodGet,
+			path:   "/v1/trial/stats?stat=mode",
+			code:   http.StatusBadRequest,
+			res:    `{"error":"unknown stat: mode"}`,
+		},
+		{
+			name:   "bad time",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			body:   `{"action":"jump", "time":"fast"}`,
+			code:   http.StatusBadRequest,
+			res:    `{"error":"invalid time"}`,
+		},
+		{
+			name:   "far future at",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			body:   `{"action":"jump", "time":100, "at":"9999-01-01T00:00:00Z"}`,
+			code:   http.StatusBadRequest,
+			res:    `{"error":"at is too far in the future: 9999-01-01T00:00:00Z"}`,
+		},
+		{
+			name:   "bad input",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			body:   `{"action":"jump", "time":`,
+			code:   http.StatusBadRequest,
+			res:    `{"error":"bad input"}`,
+		},
+		{
+			name:   "no input",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			code:   http.StatusBadRequest,
+			res:    `{"error":"no input provided"}`,
+		},
+		{
+			name:   "too large",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			body:   `{"action":"jump", "time":100, "pad":"` + strings.Repeat("a", maxActionSize) + `"}`,
+			code:   http.StatusRequestEntityTooLarge,
+			res:    `{"error":"request body too large"}`,
+		},
+		{
+			name:   "unknown scoreType",
+			method: http.MethodPost,
+			path:   "/v1/race/actions",
+			body:   `{"action":"jump", "time":100}`,
+			code:   http.StatusNotFound,
+			res:    `{"error":"invalid ScoreType"}`,
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodGet,
+			path:   "/v1/trial/actions",
+			code:   http.StatusMethodNotAllowed,
+			res:    `{"error":"method not allowed"}`,
+		},
+		{
+			name:   "add hop",
+			method: http.MethodPost,
+			path:   "/v1/trial/actions",
+			body:   `{"action":"hop", "time":50}`,
+			code:   http.StatusNoContent,
+		},
+		{
+			name:   "sorted",
+			method: http.MethodGet,
+			path:   "/v1/trial/stats?order=-avg",
+			code:   http.StatusOK,
+			res:    `[{"action":"jump","avg":150},{"action":"hop","avg":50}]`,
+		},
+		{
+			name:   "top",
+			method: http.MethodGet,
+			path:   "/v1/trial/stats?stat=count&order=max&limit=1",
+			code:   http.StatusOK,
+			res:    `[{"action":"hop","count":1,"max":50}]`,
+		},
+		{
+			name:   "bad limit",
+			method: http.MethodGet,
+			path:   "/v1/trial/stats?limit=ten",
+			code:   http.StatusBadRequest,
+			res:    `{"error":"invalid limit \"ten\""}`,
+		},
+		{
+			name:   "metrics",
+			method: http.MethodGet,
+			path:   "/v1/trial/metrics?stat=time",
+			code:   http.StatusOK,
+			res: `# TYPE trial_time histogram
+trial_time_bucket{action="hop",le="100"} 1
+trial_time_bucket{action="hop",le="+Inf"} 1
+trial_time_sum{action="hop"} 50
+trial_time_count{action="hop"} 1
+trial_time_bucket{action="jump",le="100"} 1
+trial_time_bucket{action="jump",le="+Inf"} 2
+trial_time_sum{action="jump"} 300
+trial_time_count{action="jump"} 2`,
+		},
+		{
+			name:   "metrics of a stat that isn't a histogram",
+			method: http.MethodGet,
+			path:   "/v1/trial/metrics?stat=avg",
+			code:   http.StatusBadRequest,
+			res:    `{"error":"not a histogram: avg"}`,
+		},
+		{
+			name:   "unknown route",
+			method: http.MethodGet,
+			path:   "/v1/trial",
+			code:   http.StatusNotFound,
+			res:    `{"error":"not found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		code, res := do(t, srv, tc.method, tc.path, tc.body)
+		if expected, got := tc.code, code; expected != got {
+			t.Errorf("[%s] Expected status %d but got %d", tc.name, expected, got)
+		}
+		if expected, got := tc.res, res; expected != got {
+			t.Errorf("[%s] Expected '%s' but got '%s'", tc.name, expected, got)
+		}
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	type testCase struct {
+		name string
+		err  error
+		code int
+	}
+
+	testCases := []testCase{
+		{
+			name: "bad input",
+			err:  score.ErrBadInput,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "bad subscription",
+			err:  fmt.Errorf("%w: negative buffer", scorekeeper.ErrBadSubscription),
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "outlier",
+			err:  &scorekeeper.OutlierError{ScoreType: "trial", Action: "jump"},
+			code: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "deadline",
+			err:  fmt.Errorf("failed to query scores: %w", context.DeadlineExceeded),
+			code: http.StatusGatewayTimeout,
+		},
+		{
+			name: "canceled",
+			err:  fmt.Errorf("failed to query scores: %w", context.Canceled),
+			code: statusClientClosedRequest,
+		},
+		{
+			name: "unknown",
+			err:  errors.New("disk on fire"),
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		if expected, got := tc.code, statusOf(tc.err); expected != got {
+			t.Errorf("[%s] Expected status %d but got %d", tc.name, expected, got)
+		}
+	}
+}
+
+func TestServerNotRunning(t *testing.T) {
+	srv, sk := newTestServer(t)
+	if err := sk.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	code, res := do(t, srv, http.MethodPost, "/v1/trial/actions", `{"action":"jump", "time":100}`)
+	if expected, got := http.StatusServiceUnavailable, code; expected != got {
+		t.Errorf("Expected status %d but got %d", expected, got)
+	}
+
+	var body errorBody
+	if err := json.Unmarshal([]byte(res), &body); err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := scorekeeper.ErrNotRunning.Error(), body.Error; expected != got {
+		t.Errorf("Expected error '%s' but got '%s'", expected, got)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	srv, sk := newTestServer(t)
+
+	if err := sk.AddAction("trial", `{"action":"jump", "time":100}`); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/trial/stream?stat=avg,count", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if expected, got := http.StatusOK, res.StatusCode; expected != got {
+		t.Fatalf("Expected status %d but got %d", expected, got)
+	}
+	if expected, got := "text/event-stream", res.Header.Get("Content-Type"); expected != got {
+		t.Errorf("Expected content type '%s' but got '%s'", expected, got)
+	}
+
+	events := bufio.NewReader(res.Body)
+	event := func() string {
+		var lines []string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read event: %v", err)
+			}
+			if line = strings.TrimSpace(line); line == "" {
+				return strings.Join(lines, "\n")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if expected, got := `event: stats
+data: {"stats":[{"action":"jump","avg":100,"count":1}]}`, event(); expected != got {
+		t.Errorf("Expected first event '%s' but got '%s'", expected, got)
+	}
+
+	if code, res := do(t, srv, http.MethodPost, "/v1/trial/actions", `{"action":"hop", "time":50}`); code != http.StatusNoContent {
+		t.Fatalf("failed to add action: %d %s", code, res)
+	}
+	if expected, got := `event: stats
+data: {"actions":["hop"],"stats":[{"action":"hop","avg":50,"count":1},{"action":"jump","avg":100,"count":1}]}`, event(); expected != got {
+		t.Errorf("Expected event '%s' but got '%s'", expected, got)
+	}
+
+	type testCase struct {
+		name   string
+		method string
+		path   string
+		code   int
+		res    string
+	}
+	testCases := []testCase{
+		{
+			name:   "unknown scoreType",
+			method: http.MethodGet,
+			path:   "/v1/race/stream",
+			code:   http.StatusNotFound,
+			res:    `{"error":"invalid ScoreType"}`,
+		},
+		{
+			name:   "unknown stat",
+			method: http.MethodGet,
+			path:   "/v1/trial/stream?stat=mode",
+			code:   http.StatusBadRequest,
+			res:    `{"error":"unknown stat: mode"}`,
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodPost,
+			path:   "/v1/trial/stream",
+			code:   http.StatusMethodNotAllowed,
+			res:    `{"error":"method not allowed"}`,
+		},
+	}
+	for _, tc := range testCases {
+		code, res := do(t, srv, tc.method, tc.path, "")
+		if expected, got := tc.code, code; expected != got {
+			t.Errorf("[%s] Expected status %d but got %d", tc.name, expected, got)
+		}
+		if expected, got := tc.res, res; expected != got {
+			t.Errorf("[%s] Expected '%s' but got '%s'", tc.name, expected, got)
+		}
+	}
+}
+
+func TestServerShutdownStreams(t *testing.T) {
+	_, sk := newTestServer(t)
+
+	h := New(sk)
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.RegisterOnShutdown(h.CloseStreams)
+	srv.Start()
+	defer srv.Close()
+
+	res, err := srv.Client().Get(srv.URL + "/v1/trial/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if expected, got := http.StatusOK, res.StatusCode; expected != got {
+		t.Fatalf("Expected status %d but got %d", expected, got)
+	}
+
+	// an open stream doesn't hold up Shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected the stream to end for Shutdown but got '%v'", err)
+	}
+
+	if _, err := ioutil.ReadAll(res.Body); err != nil {
+		t.Errorf("Expected the stream to end cleanly but got '%v'", err)
+	}
+}
@@ -0,0 +1,139 @@
+package scorekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+// ErrNotSketch is returned for a stat that isn't kept in a stat.Sketch, like "avg" or a window.
+var ErrNotSketch = errors.New("not a sketch")
+
+// sketchResult is a copy of a running stat's sketch, or an error.
+type sketchResult struct {
+	sketch *stat.Sketch
+	err    error
+}
+
+// sketchEnvelope asks the worker for the sketch of an action's stat, merging another into it first if there is one.
+type sketchEnvelope struct {
+	ctx       context.Context
+	scoreType string
+	action    string
+	stat      string
+	merge     *stat.Sketch
+	r         chan sketchResult
+}
+
+// Sketch returns a copy of the stat.Sketch kept by an approximate percentile stat, like "approx_p99",
+// for an action of scoreType, to save as json alongside the store or merge with the sketches of other ScoreKeepers.
+// It returns ErrNotSketch for a stat that isn't an approximate percentile, and stat.ErrNoData for an action with no scores.
+func (sk *ScoreKeeper) Sketch(scoreType, action, statName string) (*stat.Sketch, error) {
+	return sk.SketchContext(context.Background(), scoreType, action, statName)
+}
+
+// SketchContext is Sketch with a context.
+// It gives up and returns ctx.Err() if the context is done before the sketch is ready.
+func (sk *ScoreKeeper) SketchContext(ctx context.Context, scoreType, action, statName string) (*stat.Sketch, error) {
+	return sk.sketch(ctx, scoreType, action, statName, nil)
+}
+
+// MergeSketch merges s into the sketch kept by an approximate percentile stat, like "approx_p99",
+// for an action of scoreType, so its percentiles cover the values of both,
+// like those kept by another ScoreKeeper, or saved with Sketch before a restart.
+// Merged values aren't in the store, so Start forgets them: merge them again after it.
+// They don't count towards the action's Count either.
+// Each approximate percentile stat keeps its own sketch, so merging into "approx_p99" leaves "approx_p50" as it was:
+// merge into every approx_pNN stat of the action to keep them in agreement.
+// s must have the same accuracy as the stat, or it returns stat.ErrSketchMismatch.
+func (sk *ScoreKeeper) MergeSketch(scoreType, action, statName string, s *stat.Sketch) error {
+	return sk.MergeSketchContext(context.Background(), scoreType, action, statName, s)
+}
+
+// MergeSketchContext is MergeSketch with a context.
+// It gives up and returns ctx.Err() if the context is done before the sketch is merged.
+func (sk *ScoreKeeper) MergeSketchContext(ctx context.Context, scoreType, action, statName string, s *stat.Sketch) error {
+	if s == nil {
+		return fmt.Errorf("%w: nil", ErrNotSketch)
+	}
+
+	_, err := sk.sketch(ctx, scoreType, action, statName, s)
+	return err
+}
+
+// sketch asks the worker for a copy of the sketch of an action's stat, merging merge into it first if it is set.
+func (sk *ScoreKeeper) sketch(ctx context.Context, scoreType, action, statName string, merge *stat.Sketch) (*stat.Sketch, error) {
+	if sk.s == nil {
+		return nil, ErrNoKeeper
+	}
+	w, err := sk.channels()
+	if err != nil {
+		return nil, err
+	}
+	if valid := ValidScoreType(sk, scoreType); !valid {
+		return nil, score.ErrBadScoreType
+	}
+	if _, err := stat.Create(sk.stats, statName); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, statName)
+	}
+
+	sketchCh := make(chan sketchResult, 1)
+	select {
+	case w.sketches <- sketchEnvelope{
+		ctx:       ctx,
+		scoreType: scoreType,
+		action:    action,
+		stat:      statName,
+		merge:     merge,
+		r:         sketchCh,
+	}:
+	case <-w.quit:
+		return nil, ErrNotRunning
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case res := <-sketchCh:
+		return res.sketch, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sketched finds the sketch of an action's running stat for the worker, catching the stat up from the store
+// if it isn't running yet, and merges merge into it if it is set. It returns a copy of the sketch.
+func (sk *ScoreKeeper) sketched(ctx context.Context, scoreType, action, statName string, merge *stat.Sketch) (*stat.Sketch, error) {
+	if err := sk.catchUp(ctx, sk.aggs, scoreType, store.Query{}, []string{statName}); err != nil {
+		return nil, err
+	}
+
+	run, ok := sk.aggs.m[scoreType][action][statName]
+	if !ok {
+		return nil, stat.ErrNoData
+	}
+	if run.err != nil {
+		return nil, run.err
+	}
+
+	p, ok := run.st.(*stat.ApproxPercentile)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotSketch, statName)
+	}
+	s, err := p.Sketch()
+	if err != nil {
+		return nil, err
+	}
+
+	if merge != nil {
+		if err := s.Merge(merge); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.Copy(), nil
+}
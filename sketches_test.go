@@ -0,0 +1,117 @@
+package scorekeeper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/stat"
+)
+
+func TestSketch(t *testing.T) {
+	a := newStartedKeeper(t)
+	b := newStartedKeeper(t)
+
+	// each keeper has half the scores of jump
+	for i := 1; i <= 100; i++ {
+		if err := a.AddAction("trial", fmt.Sprintf(`{"action":"jump", "time":%d}`, i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.AddAction("trial", fmt.Sprintf(`{"action":"jump", "time":%d}`, i+100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a's sketch is saved, then restored and merged into b's
+	sk, err := a.Sketch("trial", "jump", "approx_p50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := uint64(100), sk.Count(); expected != got {
+		t.Errorf("Expected a sketch of %d scores but got %d", expected, got)
+	}
+
+	saved, err := json.Marshal(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &stat.Sketch{}
+	if err := json.Unmarshal(saved, restored); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.MergeSketch("trial", "jump", "approx_p50", restored); err != nil {
+		t.Fatal(err)
+	}
+
+	// the median of 1 to 200, within 1%
+	r, err := b.Stats("trial", "approx_p50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Actions[0].Values["approx_p50"].(float64); math.Abs(got-100) > 1 {
+		t.Errorf("Expected approx_p50 within 1%% of 100 but got %v", got)
+	}
+
+	merged, err := b.Sketch("trial", "jump", "approx_p50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := uint64(200), merged.Count(); expected != got {
+		t.Errorf("Expected a merged sketch of %d scores but got %d", expected, got)
+	}
+
+	// the sketch returned is a copy
+	_ = sk.Add(1000)
+	if again, _ := a.Sketch("trial", "jump", "approx_p50"); again.Count() != 100 {
+		t.Errorf("Expected the keeper's sketch not to change with its copy, but it has %d scores", again.Count())
+	}
+
+	type testCase struct {
+		name   string
+		action string
+		stat   string
+		merge  *stat.Sketch
+		err    error
+	}
+	coarse, _ := stat.NewSketch(0.05, 0)
+	testCases := []testCase{
+		{
+			name:   "not a sketch",
+			action: "jump",
+			stat:   "avg",
+			err:    ErrNotSketch,
+		},
+		{
+			name:   "no scores",
+			action: "hop",
+			stat:   "approx_p50",
+			err:    stat.ErrNoData,
+		},
+		{
+			name:   "unknown stat",
+			action: "jump",
+			stat:   "approx",
+			err:    stat.ErrUnknownStat,
+		},
+		{
+			name:   "different accuracy",
+			action: "jump",
+			stat:   "approx_p99",
+			merge:  coarse,
+			err:    stat.ErrSketchMismatch,
+		},
+	}
+	for _, tc := range testCases {
+		var err error
+		if tc.merge != nil {
+			err = a.MergeSketch("trial", tc.action, tc.stat, tc.merge)
+		} else {
+			_, err = a.Sketch("trial", tc.action, tc.stat)
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package stat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+var ErrBadBuckets = errors.New("histogram bounds must be finite and ascending")
+
+// Histogram is a Stat that counts scores with float64 values into buckets, to chart how they are spread.
+// Each bucket counts the values above the bound before it, up to and including its own,
+// and values above the last bound are counted in a final bucket up to +Inf.
+type Histogram struct {
+	// Bounds are the upper bounds of the buckets, ascending. See LinearBuckets and ExponentialBuckets.
+	Bounds []float64
+
+	// counts by bucket, with the +Inf bucket last
+	counts []uint64
+	sum    float64
+	n      uint64
+}
+
+// LinearBuckets returns n bounds, width apart, starting at start.
+// It returns nil unless n and width are positive.
+func LinearBuckets(start, width float64, n int) []float64 {
+	if n < 1 || !(width > 0) {
+		return nil
+	}
+
+	bounds := make([]float64, n)
+	for i := range bounds {
+		bounds[i] = start + float64(i)*width
+	}
+	return bounds
+}
+
+// ExponentialBuckets returns n bounds, each factor times the one before, starting at start.
+// It returns nil unless n and start are positive and factor is more than 1.
+func ExponentialBuckets(start, factor float64, n int) []float64 {
+	if n < 1 || !(start > 0) || !(factor > 1) {
+		return nil
+	}
+
+	bounds := make([]float64, n)
+	for i := range bounds {
+		bounds[i] = start * math.Pow(factor, float64(i))
+	}
+	return bounds
+}
+
+// Compute the histogram of a list of scores.
+func (h *Histogram) Compute(ss []score.Score) (interface{}, error) {
+	c := Histogram{Bounds: h.Bounds}
+	for _, s := range ss {
+		if err := c.Step(s); err != nil {
+			return HistogramReport{}, err
+		}
+	}
+
+	return c.Report()
+}
+
+// Step counts the score in its bucket.
+func (h *Histogram) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	if h.counts == nil {
+		if err := checkBounds(h.Bounds); err != nil {
+			return err
+		}
+		h.counts = make([]uint64, len(h.Bounds)+1)
+	}
+
+	h.counts[sort.SearchFloat64s(h.Bounds, v)]++
+	h.sum += v
+	h.n++
+	return nil
+}
+
+// Report the counts so far, as a HistogramReport.
+func (h *Histogram) Report() (interface{}, error) {
+	if err := checkBounds(h.Bounds); err != nil {
+		return HistogramReport{}, err
+	}
+	if h.n == 0 {
+		return HistogramReport{}, ErrNoData
+	}
+
+	r := HistogramReport{
+		Buckets: make([]Bucket, len(h.counts)),
+		Count:   h.n,
+		Sum:     h.sum,
+	}
+	for i, n := range h.counts {
+		le := math.Inf(1)
+		if i < len(h.Bounds) {
+			le = h.Bounds[i]
+		}
+		r.Buckets[i] = Bucket{Le: le, Count: n}
+	}
+
+	return r, nil
+}
+
+// checkBounds are usable as the bounds of a Histogram.
+func checkBounds(bounds []float64) error {
+	if len(bounds) == 0 {
+		return ErrBadBuckets
+	}
+
+	for i, b := range bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) || (i > 0 && b <= bounds[i-1]) {
+			return ErrBadBuckets
+		}
+	}
+	return nil
+}
+
+// HistogramReport is what a Histogram reports: the count in each bucket, and the count and sum of all values.
+type HistogramReport struct {
+	Buckets []Bucket `json:"buckets"`
+	Count   uint64   `json:"count"`
+	Sum     float64  `json:"sum"`
+}
+
+// Bucket of a HistogramReport, counting the values up to Le that were above the bucket before it.
+type Bucket struct {
+	Le    float64
+	Count uint64
+}
+
+// MarshalJSON encodes the bucket like {"le":100,"count":3}, with the last bound as "+Inf".
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	var le interface{} = b.Le
+	if math.IsInf(b.Le, 1) {
+		le = "+Inf"
+	}
+
+	return json.Marshal(struct {
+		Le    interface{} `json:"le"`
+		Count uint64      `json:"count"`
+	}{le, b.Count})
+}
+
+// Prometheus renders the report as the samples of a Prometheus histogram called name, with the labels:
+// a cumulative name_bucket sample per bound, then name_sum and name_count.
+func (r HistogramReport) Prometheus(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, labelEscaper.Replace(labels[k])))
+	}
+	series := "{" + strings.Join(pairs, ",") + "}"
+	if len(pairs) == 0 {
+		series = ""
+	}
+
+	var b strings.Builder
+	var cumulative uint64
+	for _, bucket := range r.Buckets {
+		cumulative += bucket.Count
+		le := fmt.Sprintf("le=%q", promFloat(bucket.Le))
+		fmt.Fprintf(&b, "%s_bucket{%s} %d\n", name, strings.Join(append(pairs[:len(pairs):len(pairs)], le), ","), cumulative)
+	}
+	fmt.Fprintf(&b, "%s_sum%s %s\n", name, series, promFloat(r.Sum))
+	fmt.Fprintf(&b, "%s_count%s %d\n", name, series, r.Count)
+
+	return b.String()
+}
+
+// labelEscaper escapes label values for Prometheus.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promFloat formats f as Prometheus does.
+func promFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,172 @@
+package stat
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+func TestBuckets(t *testing.T) {
+	if expected, got := []float64{0, 50, 100, 150}, LinearBuckets(0, 50, 4); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected linear buckets %v but got %v", expected, got)
+	}
+	if expected, got := []float64{1, 10, 100}, ExponentialBuckets(1, 10, 3); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected exponential buckets %v but got %v", expected, got)
+	}
+	if got := LinearBuckets(0, 0, 4); got != nil {
+		t.Errorf("Expected no buckets for a zero width but got %v", got)
+	}
+	if got := ExponentialBuckets(1, 1, 4); got != nil {
+		t.Errorf("Expected no buckets for a factor of 1 but got %v", got)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	type testCase struct {
+		name   string
+		bounds []float64
+		ss     []score.Score
+		counts []uint64
+		sum    float64
+		err    error
+	}
+
+	testCases := []testCase{
+		{
+			name:   "provided",
+			bounds: []float64{100, 200},
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(50)},
+				&score.TestScore{TValue: float64(100)},
+				&score.TestScore{TValue: float64(150)},
+				&score.TestScore{TValue: float64(250)},
+				&score.TestScore{TValue: float64(900)},
+			},
+			counts: []uint64{2, 1, 2},
+			sum:    1450,
+		},
+		{
+			name:   "explicit",
+			bounds: []float64{-1, 0, 0.5},
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(-3)},
+				&score.TestScore{TValue: float64(0)},
+				&score.TestScore{TValue: float64(0.25)},
+			},
+			counts: []uint64{1, 1, 1, 0},
+			sum:    -2.75,
+		},
+		{
+			name:   "empty",
+			bounds: []float64{1},
+			ss:     []score.Score{},
+			err:    ErrNoData,
+		},
+		{
+			name:   "no bounds",
+			bounds: nil,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(1)},
+			},
+			err: ErrBadBuckets,
+		},
+		{
+			name:   "unordered bounds",
+			bounds: []float64{2, 1},
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(1)},
+			},
+			err: ErrBadBuckets,
+		},
+		{
+			name:   "invalid type",
+			bounds: []float64{1},
+			ss:     []score.Score{&badScore{}},
+			err:    ErrTypeInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		var want interface{} = HistogramReport{}
+		if tc.err == nil {
+			r := HistogramReport{Count: uint64(len(tc.ss)), Sum: tc.sum}
+			for i, n := range tc.counts {
+				le := math.Inf(1)
+				if i < len(tc.bounds) {
+					le = tc.bounds[i]
+				}
+				r.Buckets = append(r.Buckets, Bucket{Le: le, Count: n})
+			}
+			want = r
+		}
+
+		h := Histogram{Bounds: tc.bounds}
+		var err error
+		for _, s := range tc.ss {
+			if err = h.Step(s); err != nil {
+				break
+			}
+		}
+		res := interface{}(HistogramReport{})
+		if err == nil {
+			res, err = h.Report()
+		}
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := want, res; !reflect.DeepEqual(expected, got) {
+			t.Errorf("[%s] Expected %v but got %v", tc.name, expected, got)
+		}
+
+		res2, err := (&Histogram{Bounds: tc.bounds}).Compute(tc.ss)
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Compute: Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := want, res2; !reflect.DeepEqual(expected, got) {
+			t.Errorf("[%s] Compute: Expected %v but got %v", tc.name, expected, got)
+		}
+	}
+}
+
+func TestHistogramRender(t *testing.T) {
+	h := Histogram{Bounds: []float64{0.5, 1}}
+	for _, v := range []float64{0.25, 0.75, 1, 3} {
+		_ = h.Step(&score.TestScore{TValue: v})
+	}
+	res, err := h.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := res.(HistogramReport)
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := `{"buckets":[{"le":0.5,"count":1},{"le":1,"count":2},{"le":"+Inf","count":1}],"count":4,"sum":5}`, string(b); expected != got {
+		t.Errorf("Expected json '%s' but got '%s'", expected, got)
+	}
+
+	expected := `trial_time_bucket{action="a \"b\"",le="0.5"} 1
+trial_time_bucket{action="a \"b\"",le="1"} 3
+trial_time_bucket{action="a \"b\"",le="+Inf"} 4
+trial_time_sum{action="a \"b\""} 5
+trial_time_count{action="a \"b\""} 4
+`
+	if got := r.Prometheus("trial_time", map[string]string{"action": `a "b"`}); expected != got {
+		t.Errorf("Expected prometheus\n%s\nbut got\n%s", expected, got)
+	}
+
+	expected = `t_bucket{le="0.5"} 1
+t_bucket{le="1"} 3
+t_bucket{le="+Inf"} 4
+t_sum 5
+t_count 4
+`
+	if got := r.Prometheus("t", nil); expected != got {
+		t.Errorf("Expected prometheus\n%s\nbut got\n%s", expected, got)
+	}
+}
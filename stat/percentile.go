@@ -0,0 +1,74 @@
+package stat
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// Percentile is a Stat that computes the P-th percentile of scores with float64 values.
+// It interpolates linearly between the two closest ranks, so the median of 1 and 2 is 1.5.
+// Unlike Average it has to keep every value it is given.
+type Percentile struct {
+	// P is the percentile to compute, from 0 to 100.
+	P float64
+
+	vs []float64
+}
+
+// NewMedian returns a Percentile that computes the median.
+func NewMedian() *Percentile {
+	return &Percentile{P: 50}
+}
+
+var ErrBadPercentile = errors.New("percentile must be between 0 and 100")
+
+// Compute the percentile of a list of scores with float64 values.
+func (p *Percentile) Compute(ss []score.Score) (interface{}, error) {
+	vs := make([]float64, 0, len(ss))
+	for _, s := range ss {
+		v, ok := s.Value().(float64)
+		if !ok {
+			return float64(0), ErrTypeInvalid
+		}
+		vs = append(vs, v)
+	}
+
+	return percentile(vs, p.P)
+}
+
+// Step adds a score to the values kept so far.
+func (p *Percentile) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	p.vs = append(p.vs, v)
+	return nil
+}
+
+// Report the percentile of the values kept so far.
+func (p *Percentile) Report() (interface{}, error) {
+	return percentile(p.vs, p.P)
+}
+
+// percentile of vs, which is sorted in place.
+func percentile(vs []float64, p float64) (float64, error) {
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return float64(0), ErrBadPercentile
+	}
+	if len(vs) == 0 {
+		return float64(0), ErrNoData
+	}
+
+	sort.Float64s(vs)
+
+	rank := p / 100 * float64(len(vs)-1)
+	lo, hi := math.Floor(rank), math.Ceil(rank)
+	low, high := vs[int(lo)], vs[int(hi)]
+
+	return low + (rank-lo)*(high-low), nil
+}
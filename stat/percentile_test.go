@@ -0,0 +1,142 @@
+package stat
+
+import (
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+func TestPercentile(t *testing.T) {
+	type testCase struct {
+		name string
+		p    float64
+		ss   []score.Score
+		res  float64
+		err  error
+	}
+
+	testCases := []testCase{
+		{
+			name: "median odd",
+			p:    50,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(300)},
+				&score.TestScore{TValue: float64(100)},
+				&score.TestScore{TValue: float64(200)},
+			},
+			res: float64(200),
+		},
+		{
+			name: "median even",
+			p:    50,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(1)},
+				&score.TestScore{TValue: float64(2)},
+			},
+			res: float64(1.5),
+		},
+		{
+			name: "empty",
+			p:    50,
+			ss:   []score.Score{},
+			err:  ErrNoData,
+		},
+		{
+			name: "one",
+			p:    99,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(7)},
+			},
+			res: float64(7),
+		},
+		{
+			name: "p90",
+			p:    90,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(1)},
+				&score.TestScore{TValue: float64(2)},
+				&score.TestScore{TValue: float64(3)},
+				&score.TestScore{TValue: float64(4)},
+				&score.TestScore{TValue: float64(5)},
+				&score.TestScore{TValue: float64(6)},
+				&score.TestScore{TValue: float64(7)},
+				&score.TestScore{TValue: float64(8)},
+				&score.TestScore{TValue: float64(9)},
+				&score.TestScore{TValue: float64(10)},
+				&score.TestScore{TValue: float64(11)},
+			},
+			res: float64(10),
+		},
+		{
+			name: "min and max",
+			p:    0,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(-5)},
+				&score.TestScore{TValue: float64(5)},
+			},
+			res: float64(-5),
+		},
+		{
+			name: "max",
+			p:    100,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(-5)},
+				&score.TestScore{TValue: float64(5)},
+			},
+			res: float64(5),
+		},
+		{
+			name: "out of range",
+			p:    101,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(1)},
+			},
+			err: ErrBadPercentile,
+		},
+	}
+
+	for _, tc := range testCases {
+		p := Percentile{P: tc.p}
+
+		for _, s := range tc.ss {
+			if err := p.Step(s); err != nil {
+				t.Errorf("[%s] Expected no error but got '%v'", tc.name, err)
+			}
+		}
+
+		res, err := p.Report()
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := tc.res, res; expected != got {
+			t.Errorf("[%s] Expected %f but got %f", tc.name, expected, got)
+		}
+
+		res2, err := p.Compute(tc.ss)
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Compute: Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := tc.res, res2; expected != got {
+			t.Errorf("[%s] Compute: Expected %f but got %f", tc.name, expected, got)
+		}
+	}
+}
+
+func TestMedianTypeInvalid(t *testing.T) {
+	m := NewMedian()
+	if expected, got := ErrTypeInvalid, m.Step(&badScore{}); expected != got {
+		t.Errorf("Expected error to be '%v' but got '%v'", expected, got)
+	}
+	if _, err := m.Compute([]score.Score{&badScore{}}); err != ErrTypeInvalid {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrTypeInvalid, err)
+	}
+}
+
+// badScore has a value that is not a float64.
+type badScore struct {
+	score.TestScore
+}
+
+func (b *badScore) Value() interface{} {
+	return "fast"
+}
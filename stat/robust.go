@@ -0,0 +1,130 @@
+package stat
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// defaultFraction is the fraction the registered robust averages trim or winsorize from each end.
+const defaultFraction = 0.1
+
+var ErrBadFraction = errors.New("fraction must be at least 0 and below 0.5")
+
+// TrimmedMean is a Stat that averages scores with float64 values, leaving out the highest and lowest.
+// With a Fraction of 0.1 it drops the lowest 10% and highest 10% of values, so a few outliers don't move it.
+// Like Percentile it has to keep every value it is given.
+type TrimmedMean struct {
+	// Fraction of the values to drop from each end, at least 0 and below 0.5.
+	// The count dropped is rounded down, so a Fraction of 0 is the plain average.
+	Fraction float64
+
+	vs []float64
+}
+
+// Compute the trimmed mean of a list of scores with float64 values.
+func (m *TrimmedMean) Compute(ss []score.Score) (interface{}, error) {
+	vs, err := floats(ss)
+	if err != nil {
+		return float64(0), err
+	}
+
+	return trimmedMean(vs, m.Fraction, false)
+}
+
+// Step adds a score to the values kept so far.
+func (m *TrimmedMean) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	m.vs = append(m.vs, v)
+	return nil
+}
+
+// Report the trimmed mean of the values kept so far.
+func (m *TrimmedMean) Report() (interface{}, error) {
+	return trimmedMean(m.vs, m.Fraction, false)
+}
+
+// WinsorizedMean is a Stat that averages scores with float64 values, pulling in the highest and lowest.
+// With a Fraction of 0.1 the lowest 10% of values count as the lowest value left, and the highest 10% as the highest,
+// so outliers still count, but only as far as the rest reach.
+// Like Percentile it has to keep every value it is given.
+type WinsorizedMean struct {
+	// Fraction of the values to pull in from each end, at least 0 and below 0.5.
+	// The count pulled in is rounded down, so a Fraction of 0 is the plain average.
+	Fraction float64
+
+	vs []float64
+}
+
+// Compute the winsorized mean of a list of scores with float64 values.
+func (m *WinsorizedMean) Compute(ss []score.Score) (interface{}, error) {
+	vs, err := floats(ss)
+	if err != nil {
+		return float64(0), err
+	}
+
+	return trimmedMean(vs, m.Fraction, true)
+}
+
+// Step adds a score to the values kept so far.
+func (m *WinsorizedMean) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	m.vs = append(m.vs, v)
+	return nil
+}
+
+// Report the winsorized mean of the values kept so far.
+func (m *WinsorizedMean) Report() (interface{}, error) {
+	return trimmedMean(m.vs, m.Fraction, true)
+}
+
+// floats are the float64 values of ss.
+func floats(ss []score.Score) ([]float64, error) {
+	vs := make([]float64, 0, len(ss))
+	for _, s := range ss {
+		v, ok := s.Value().(float64)
+		if !ok {
+			return nil, ErrTypeInvalid
+		}
+		vs = append(vs, v)
+	}
+
+	return vs, nil
+}
+
+// trimmedMean of vs, which is sorted in place, without the lowest and highest fraction of them,
+// or, if winsorize, with them counted as the lowest and highest left.
+func trimmedMean(vs []float64, fraction float64, winsorize bool) (float64, error) {
+	if !(fraction >= 0 && fraction < 0.5) {
+		return float64(0), ErrBadFraction
+	}
+	if len(vs) == 0 {
+		return float64(0), ErrNoData
+	}
+
+	sort.Float64s(vs)
+
+	k := int(math.Floor(fraction * float64(len(vs))))
+	kept := vs[k : len(vs)-k]
+
+	var sum float64
+	for _, v := range kept {
+		sum += v
+	}
+	if !winsorize {
+		return sum / float64(len(kept)), nil
+	}
+
+	sum += float64(k) * (kept[0] + kept[len(kept)-1])
+	return sum / float64(len(vs)), nil
+}
@@ -0,0 +1,137 @@
+package stat
+
+import (
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// values as scores.
+func values(vs ...float64) []score.Score {
+	ss := make([]score.Score, 0, len(vs))
+	for _, v := range vs {
+		ss = append(ss, &score.TestScore{TName: "jump", TValue: v})
+	}
+	return ss
+}
+
+func TestRobustMeans(t *testing.T) {
+	type testCase struct {
+		name       string
+		fraction   float64
+		ss         []score.Score
+		trimmed    float64
+		winsorized float64
+		err        error
+	}
+
+	testCases := []testCase{
+		{
+			name:       "provided",
+			fraction:   0.1,
+			ss:         values(1, 2, 4, 8, 16, 32, 64, 128, 256, 9999),
+			trimmed:    63.75,
+			winsorized: 76.8,
+		},
+		{
+			name:       "unsorted",
+			fraction:   0.1,
+			ss:         values(9999, 16, 1, 256, 2, 128, 4, 64, 8, 32),
+			trimmed:    63.75,
+			winsorized: 76.8,
+		},
+		{
+			name:       "quarter",
+			fraction:   0.25,
+			ss:         values(1, 2, 3, 5, 8, 13, 21, 1000),
+			trimmed:    7.25,
+			winsorized: 7.625,
+		},
+		{
+			name:       "none",
+			fraction:   0,
+			ss:         values(1, 2, 9),
+			trimmed:    4,
+			winsorized: 4,
+		},
+		{
+			// 10% of 5 values rounds down to none
+			name:       "rounded down",
+			fraction:   0.1,
+			ss:         values(1, 2, 3, 4, 100),
+			trimmed:    22,
+			winsorized: 22,
+		},
+		{
+			name:       "one",
+			fraction:   0.4,
+			ss:         values(7),
+			trimmed:    7,
+			winsorized: 7,
+		},
+		{
+			name:     "empty",
+			fraction: 0.1,
+			ss:       []score.Score{},
+			err:      ErrNoData,
+		},
+		{
+			name:     "half",
+			fraction: 0.5,
+			ss:       values(1, 2),
+			err:      ErrBadFraction,
+		},
+		{
+			name:     "negative",
+			fraction: -0.1,
+			ss:       values(1, 2),
+			err:      ErrBadFraction,
+		},
+	}
+
+	for _, tc := range testCases {
+		stats := map[string]Stat{
+			"trimmed":    &TrimmedMean{Fraction: tc.fraction},
+			"winsorized": &WinsorizedMean{Fraction: tc.fraction},
+		}
+		want := map[string]float64{
+			"trimmed":    tc.trimmed,
+			"winsorized": tc.winsorized,
+		}
+
+		for name, st := range stats {
+			for _, s := range tc.ss {
+				if err := st.Step(s); err != nil {
+					t.Errorf("[%s] %s: Expected no error but got '%v'", tc.name, name, err)
+				}
+			}
+
+			res, err := st.Report()
+			if expected, got := tc.err, err; expected != got {
+				t.Errorf("[%s] %s: Expected error to be '%v' but got '%v'", tc.name, name, expected, got)
+			}
+			if expected, got := want[name], res; expected != got {
+				t.Errorf("[%s] %s: Expected %f but got %f", tc.name, name, expected, got)
+			}
+
+			res2, err := st.Compute(tc.ss)
+			if expected, got := tc.err, err; expected != got {
+				t.Errorf("[%s] %s Compute: Expected error to be '%v' but got '%v'", tc.name, name, expected, got)
+			}
+			if expected, got := want[name], res2; expected != got {
+				t.Errorf("[%s] %s Compute: Expected %f but got %f", tc.name, name, expected, got)
+			}
+		}
+	}
+}
+
+func TestRobustMeansTypeInvalid(t *testing.T) {
+	for name, st := range map[string]Stat{"trimmed": &TrimmedMean{}, "winsorized": &WinsorizedMean{}} {
+		if expected, got := ErrTypeInvalid, st.Step(&badScore{}); expected != got {
+			t.Errorf("%s: Expected error to be '%v' but got '%v'", name, expected, got)
+		}
+		if _, err := st.Compute([]score.Score{&badScore{}}); err != ErrTypeInvalid {
+			t.Errorf("%s Compute: Expected error to be '%v' but got '%v'", name, ErrTypeInvalid, err)
+		}
+	}
+}
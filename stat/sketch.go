@@ -0,0 +1,356 @@
+package stat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// DefaultAlpha is the relative accuracy of a Sketch unless another is given: within 1% of the true value.
+const DefaultAlpha = 0.01
+
+// DefaultMaxBins bounds the buckets a Sketch keeps on each side of zero unless another bound is given.
+// At DefaultAlpha that covers magnitudes spanning a factor of 10^17 before collapsing any.
+const DefaultMaxBins = 2048
+
+var ErrBadAlpha = errors.New("sketch accuracy must be between 0 and 1")
+var ErrBadValue = errors.New("sketch values must be finite")
+var ErrSketchMismatch = errors.New("sketches with different accuracies can't be merged")
+
+// Sketch estimates quantiles of float64 values in bounded memory, in the style of DDSketch.
+// Values are counted in buckets whose bounds grow geometrically, so any quantile it reports
+// is within a relative error of Alpha of the true value at that rank.
+// Sketches with the same Alpha can be merged, like those kept per shard or per node,
+// and saved and restored as json.
+//
+// Once a side of zero has more than MaxBins buckets, its smallest ones are collapsed together:
+// the quantiles of the values of smallest magnitude lose their accuracy bound, the rest keep it.
+type Sketch struct {
+	alpha   float64
+	maxBins int
+
+	// gamma is the ratio between the bounds of a bucket
+	gamma   float64
+	lnGamma float64
+
+	// pos and neg count values by the bucket index of their magnitude
+	pos, neg map[int]uint64
+	zero     uint64
+
+	count    uint64
+	min, max float64
+}
+
+// NewSketch returns an empty Sketch with a relative accuracy of alpha, between 0 and 1,
+// and keeping at most maxBins buckets on each side of zero. A maxBins below 1 means DefaultMaxBins.
+func NewSketch(alpha float64, maxBins int) (*Sketch, error) {
+	if !(alpha > 0 && alpha < 1) {
+		return nil, ErrBadAlpha
+	}
+	if maxBins < 1 {
+		maxBins = DefaultMaxBins
+	}
+
+	gamma := (1 + alpha) / (1 - alpha)
+	return &Sketch{
+		alpha:   alpha,
+		maxBins: maxBins,
+		gamma:   gamma,
+		lnGamma: math.Log(gamma),
+		pos:     map[int]uint64{},
+		neg:     map[int]uint64{},
+	}, nil
+}
+
+// Alpha is the relative accuracy of the sketch.
+func (s *Sketch) Alpha() float64 {
+	return s.alpha
+}
+
+// Count is the number of values added to the sketch.
+func (s *Sketch) Count() uint64 {
+	return s.count
+}
+
+// Bins is the number of buckets the sketch keeps, which bounds its memory.
+func (s *Sketch) Bins() int {
+	return len(s.pos) + len(s.neg)
+}
+
+// Copy returns a copy of the sketch, which changes independently of it.
+func (s *Sketch) Copy() *Sketch {
+	c := *s
+	c.pos = make(map[int]uint64, len(s.pos))
+	for i, n := range s.pos {
+		c.pos[i] = n
+	}
+	c.neg = make(map[int]uint64, len(s.neg))
+	for i, n := range s.neg {
+		c.neg[i] = n
+	}
+
+	return &c
+}
+
+// Add a value to the sketch.
+func (s *Sketch) Add(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return ErrBadValue
+	}
+
+	switch {
+	case v > 0:
+		s.pos[s.index(v)]++
+		s.collapse(s.pos)
+	case v < 0:
+		s.neg[s.index(-v)]++
+		s.collapse(s.neg)
+	default:
+		s.zero++
+	}
+
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+	return nil
+}
+
+// Merge the values of o into the sketch. Both must have the same accuracy.
+func (s *Sketch) Merge(o *Sketch) error {
+	if s.alpha != o.alpha {
+		return ErrSketchMismatch
+	}
+	if o.count == 0 {
+		return nil
+	}
+
+	for i, n := range o.pos {
+		s.pos[i] += n
+	}
+	for i, n := range o.neg {
+		s.neg[i] += n
+	}
+	s.collapse(s.pos)
+	s.collapse(s.neg)
+	s.zero += o.zero
+
+	if s.count == 0 || o.min < s.min {
+		s.min = o.min
+	}
+	if s.count == 0 || o.max > s.max {
+		s.max = o.max
+	}
+	s.count += o.count
+	return nil
+}
+
+// Quantile estimates the q-th quantile of the values added, from 0 for the smallest to 1 for the largest.
+func (s *Sketch) Quantile(q float64) (float64, error) {
+	if !(q >= 0 && q <= 1) {
+		return float64(0), ErrBadPercentile
+	}
+	if s.count == 0 {
+		return float64(0), ErrNoData
+	}
+
+	// the rank Percentile interpolates from, rounded down
+	rank := uint64(q * float64(s.count-1))
+
+	var seen uint64
+	// negative values, from the largest magnitude down
+	for _, i := range sortedKeys(s.neg, true) {
+		if seen += s.neg[i]; seen > rank {
+			return s.clamp(-s.value(i)), nil
+		}
+	}
+	if seen += s.zero; seen > rank {
+		return 0, nil
+	}
+	for _, i := range sortedKeys(s.pos, false) {
+		if seen += s.pos[i]; seen > rank {
+			return s.clamp(s.value(i)), nil
+		}
+	}
+
+	return s.max, nil
+}
+
+// index of the bucket holding a positive value v: values in (gamma^(i-1), gamma^i] are in bucket i.
+func (s *Sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.lnGamma))
+}
+
+// value representing bucket i, within alpha of any value in it.
+func (s *Sketch) value(i int) float64 {
+	return 2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)
+}
+
+// clamp v to the values added, so the smallest and largest quantiles are exact.
+func (s *Sketch) clamp(v float64) float64 {
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+// collapse the smallest buckets of bins into the next smallest until there are at most maxBins.
+func (s *Sketch) collapse(bins map[int]uint64) {
+	if len(bins) <= s.maxBins {
+		return
+	}
+
+	keys := sortedKeys(bins, false)
+	excess := len(keys) - s.maxBins
+	into := keys[excess]
+	for _, i := range keys[:excess] {
+		bins[into] += bins[i]
+		delete(bins, i)
+	}
+}
+
+// sortedKeys of bins, in ascending order or, if desc, descending.
+func sortedKeys(bins map[int]uint64, desc bool) []int {
+	keys := make([]int, 0, len(bins))
+	for i := range bins {
+		keys = append(keys, i)
+	}
+
+	if desc {
+		sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	} else {
+		sort.Ints(keys)
+	}
+	return keys
+}
+
+// sketchJSON is how a Sketch is saved.
+type sketchJSON struct {
+	Alpha   float64        `json:"alpha"`
+	MaxBins int            `json:"maxBins"`
+	Count   uint64         `json:"count"`
+	Min     float64        `json:"min"`
+	Max     float64        `json:"max"`
+	Zero    uint64         `json:"zero,omitempty"`
+	Pos     map[int]uint64 `json:"pos,omitempty"`
+	Neg     map[int]uint64 `json:"neg,omitempty"`
+}
+
+// MarshalJSON saves the sketch, to be restored with UnmarshalJSON.
+func (s *Sketch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sketchJSON{
+		Alpha:   s.alpha,
+		MaxBins: s.maxBins,
+		Count:   s.count,
+		Min:     s.min,
+		Max:     s.max,
+		Zero:    s.zero,
+		Pos:     s.pos,
+		Neg:     s.neg,
+	})
+}
+
+// UnmarshalJSON restores a sketch saved by MarshalJSON.
+func (s *Sketch) UnmarshalJSON(b []byte) error {
+	var j sketchJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	restored, err := NewSketch(j.Alpha, j.MaxBins)
+	if err != nil {
+		return err
+	}
+
+	var n uint64
+	for i, c := range j.Pos {
+		restored.pos[i] = c
+		n += c
+	}
+	for i, c := range j.Neg {
+		restored.neg[i] = c
+		n += c
+	}
+	if n+j.Zero != j.Count {
+		return fmt.Errorf("invalid sketch: count %d but buckets hold %d", j.Count, n+j.Zero)
+	}
+	restored.collapse(restored.pos)
+	restored.collapse(restored.neg)
+	restored.zero, restored.count, restored.min, restored.max = j.Zero, j.Count, j.Min, j.Max
+
+	*s = *restored
+	return nil
+}
+
+// ApproxPercentile is a Stat that estimates the P-th percentile of scores with float64 values with a Sketch.
+// Unlike Percentile it doesn't keep the values, so its memory is bounded however many scores it is given,
+// and its result is within a relative error of Alpha of the score at the percentile's rank, rounded down.
+type ApproxPercentile struct {
+	// P is the percentile to compute, from 0 to 100.
+	P float64
+	// Alpha is the relative accuracy, like 0.01 for 1%. Zero means DefaultAlpha.
+	Alpha float64
+
+	sk *Sketch
+}
+
+// Compute the approximate percentile of a list of scores with float64 values.
+func (p *ApproxPercentile) Compute(ss []score.Score) (interface{}, error) {
+	c := ApproxPercentile{P: p.P, Alpha: p.Alpha}
+	for _, s := range ss {
+		if err := c.Step(s); err != nil {
+			return float64(0), err
+		}
+	}
+
+	return c.Report()
+}
+
+// Step adds a score to the sketch.
+func (p *ApproxPercentile) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	sk, err := p.Sketch()
+	if err != nil {
+		return err
+	}
+	return sk.Add(v)
+}
+
+// Report the approximate percentile of the scores so far.
+func (p *ApproxPercentile) Report() (interface{}, error) {
+	if p.P < 0 || p.P > 100 || math.IsNaN(p.P) {
+		return float64(0), ErrBadPercentile
+	}
+
+	sk, err := p.Sketch()
+	if err != nil {
+		return float64(0), err
+	}
+	return sk.Quantile(p.P / 100)
+}
+
+// Sketch returns the sketch of the scores so far, to merge with others or save.
+func (p *ApproxPercentile) Sketch() (*Sketch, error) {
+	if p.sk == nil {
+		alpha := p.Alpha
+		if alpha == 0 {
+			alpha = DefaultAlpha
+		}
+
+		sk, err := NewSketch(alpha, 0)
+		if err != nil {
+			return nil, err
+		}
+		p.sk = sk
+	}
+
+	return p.sk, nil
+}
@@ -0,0 +1,312 @@
+package stat
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// exact is the value at quantile q of sorted vs, at the rank a Sketch estimates.
+func exact(vs []float64, q float64) float64 {
+	return vs[int(q*float64(len(vs)-1))]
+}
+
+// within reports whether got is within a relative error of alpha of want.
+func within(want, got, alpha float64) bool {
+	// a little slack for rounding at the bucket bounds
+	return math.Abs(got-want) <= alpha*math.Abs(want)*(1+1e-9)
+}
+
+func TestSketchAccuracy(t *testing.T) {
+	type testCase struct {
+		name  string
+		alpha float64
+		gen   func(r *rand.Rand) float64
+	}
+
+	testCases := []testCase{
+		{
+			name:  "uniform",
+			alpha: 0.01,
+			gen:   func(r *rand.Rand) float64 { return r.Float64() * 1000 },
+		},
+		{
+			name:  "exponential",
+			alpha: 0.01,
+			gen:   func(r *rand.Rand) float64 { return r.ExpFloat64() * 100 },
+		},
+		{
+			name:  "heavy tail",
+			alpha: 0.02,
+			gen:   func(r *rand.Rand) float64 { return math.Exp(r.NormFloat64() * 4) },
+		},
+		{
+			name:  "either side of zero",
+			alpha: 0.005,
+			gen:   func(r *rand.Rand) float64 { return r.NormFloat64() * 50 },
+		},
+		{
+			name:  "with zeros",
+			alpha: 0.01,
+			gen: func(r *rand.Rand) float64 {
+				if r.Intn(4) == 0 {
+					return 0
+				}
+				return r.Float64()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		r := rand.New(rand.NewSource(1))
+		sk, err := NewSketch(tc.alpha, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vs := make([]float64, 100000)
+		for i := range vs {
+			vs[i] = tc.gen(r)
+			if err := sk.Add(vs[i]); err != nil {
+				t.Fatalf("[%s] Expected no error but got '%v'", tc.name, err)
+			}
+		}
+		sort.Float64s(vs)
+
+		for _, q := range []float64{0, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999, 1} {
+			got, err := sk.Quantile(q)
+			if err != nil {
+				t.Fatalf("[%s] Expected no error but got '%v'", tc.name, err)
+			}
+			if want := exact(vs, q); !within(want, got, tc.alpha) {
+				t.Errorf("[%s] q%v: Expected within %v of %v but got %v", tc.name, q, tc.alpha, want, got)
+			}
+		}
+		if expected, got := uint64(len(vs)), sk.Count(); expected != got {
+			t.Errorf("[%s] Expected count %d but got %d", tc.name, expected, got)
+		}
+	}
+}
+
+func TestSketchMergeAndSave(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	// shards of the same scores, merged, match one sketch of them all
+	all, _ := NewSketch(DefaultAlpha, 0)
+	merged, _ := NewSketch(DefaultAlpha, 0)
+	for shard := 0; shard < 4; shard++ {
+		sk, _ := NewSketch(DefaultAlpha, 0)
+		for i := 0; i < 1000; i++ {
+			v := r.ExpFloat64()*float64(shard+1) - 0.5
+			_ = sk.Add(v)
+			_ = all.Add(v)
+		}
+
+		// each shard is saved, then restored to be merged
+		b, err := json.Marshal(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored := &Sketch{}
+		if err := json.Unmarshal(b, restored); err != nil {
+			t.Fatal(err)
+		}
+		if err := merged.Merge(restored); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if expected, got := all.Count(), merged.Count(); expected != got {
+		t.Errorf("Expected count %d but got %d", expected, got)
+	}
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 0.99, 1} {
+		want, _ := all.Quantile(q)
+		got, _ := merged.Quantile(q)
+		if want != got {
+			t.Errorf("q%v: Expected %v but got %v", q, want, got)
+		}
+	}
+
+	// a copy changes without changing the original
+	c := merged.Copy()
+	_ = c.Add(-100)
+	if expected, got := all.Count(), merged.Count(); expected != got {
+		t.Errorf("Expected the copied sketch's count to stay %d but got %d", expected, got)
+	}
+	if want, got := all.Count()+1, c.Count(); want != got {
+		t.Errorf("Expected the copy's count %d but got %d", want, got)
+	}
+	if lowest, _ := merged.Quantile(0); lowest == -100 {
+		t.Error("Expected a value added to the copy not to be in the original")
+	}
+
+	other, _ := NewSketch(0.05, 0)
+	if expected, got := ErrSketchMismatch, merged.Merge(other); expected != got {
+		t.Errorf("Expected error to be '%v' but got '%v'", expected, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"alpha":0.01,"count":3,"pos":{"1":1}}`), &Sketch{}); err == nil {
+		t.Error("Expected an error restoring a sketch with the wrong count")
+	}
+	if err := json.Unmarshal([]byte(`{"alpha":2}`), &Sketch{}); err != ErrBadAlpha {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrBadAlpha, err)
+	}
+}
+
+func TestSketchBounded(t *testing.T) {
+	maxBins := 64
+	sk, _ := NewSketch(DefaultAlpha, maxBins)
+
+	// values spanning far more buckets than are kept
+	vs := make([]float64, 0, 20000)
+	for i := 0; i < 20000; i++ {
+		v := math.Pow(10, float64(i%200)/10) // 1 to 1e20
+		vs = append(vs, v)
+		if err := sk.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Float64s(vs)
+
+	if sk.Bins() > maxBins {
+		t.Errorf("Expected at most %d buckets but got %d", maxBins, sk.Bins())
+	}
+
+	// the highest quantiles keep their accuracy
+	for _, q := range []float64{0.9, 0.99, 1} {
+		got, _ := sk.Quantile(q)
+		if want := exact(vs, q); !within(want, got, DefaultAlpha) {
+			t.Errorf("q%v: Expected within %v of %v but got %v", q, DefaultAlpha, want, got)
+		}
+	}
+}
+
+func TestSketchErrors(t *testing.T) {
+	if _, err := NewSketch(0, 0); err != ErrBadAlpha {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrBadAlpha, err)
+	}
+
+	sk, _ := NewSketch(DefaultAlpha, 0)
+	if _, err := sk.Quantile(0.5); err != ErrNoData {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrNoData, err)
+	}
+	if err := sk.Add(math.NaN()); err != ErrBadValue {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrBadValue, err)
+	}
+	if err := sk.Add(math.Inf(1)); err != ErrBadValue {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrBadValue, err)
+	}
+	_ = sk.Add(1)
+	if _, err := sk.Quantile(1.5); err != ErrBadPercentile {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrBadPercentile, err)
+	}
+}
+
+func TestApproxPercentile(t *testing.T) {
+	type testCase struct {
+		name string
+		p    float64
+		ss   []score.Score
+		res  float64
+		err  error
+	}
+
+	testCases := []testCase{
+		{
+			name: "one",
+			p:    99,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(7)},
+			},
+			res: float64(7),
+		},
+		{
+			name: "min",
+			p:    0,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(-5)},
+				&score.TestScore{TValue: float64(5)},
+			},
+			res: float64(-5),
+		},
+		{
+			name: "max",
+			p:    100,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(-5)},
+				&score.TestScore{TValue: float64(5)},
+			},
+			res: float64(5),
+		},
+		{
+			name: "empty",
+			p:    50,
+			ss:   []score.Score{},
+			err:  ErrNoData,
+		},
+		{
+			name: "out of range",
+			p:    101,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(1)},
+			},
+			err: ErrBadPercentile,
+		},
+		{
+			name: "invalid type",
+			p:    50,
+			ss:   []score.Score{&badScore{}},
+			err:  ErrTypeInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		p := ApproxPercentile{P: tc.p}
+
+		var err error
+		for _, s := range tc.ss {
+			if err = p.Step(s); err != nil {
+				break
+			}
+		}
+
+		res := interface{}(float64(0))
+		if err == nil {
+			res, err = p.Report()
+		}
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := tc.res, res; expected != got {
+			t.Errorf("[%s] Expected %f but got %f", tc.name, expected, got)
+		}
+
+		res2, err := p.Compute(tc.ss)
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Compute: Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if expected, got := tc.res, res2; expected != got {
+			t.Errorf("[%s] Compute: Expected %f but got %f", tc.name, expected, got)
+		}
+	}
+
+	// a median of 1..1000 within 1%, as registered
+	st, err := Create(Defaults(), "approx_p50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 1000; i++ {
+		_ = st.Step(&score.TestScore{TValue: float64(i)})
+	}
+	res, err := st.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !within(500, res.(float64), DefaultAlpha) {
+		t.Errorf("Expected within %v of 500 but got %v", DefaultAlpha, res)
+	}
+}
@@ -2,19 +2,22 @@ package stat
 
 import (
 	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bdharris08/scorekeeper/score"
 )
 
 // Stat does some math on a set of scores and returns the result.
-// We will only have one kind of Statistic for this project (Average),
-// but through this interface we could extend to other kinds easily.
+// The built-in Stats, like Average, Percentile and Histogram, are named in Defaults,
+// and others can be registered alongside them with a Factory.
 type Stat interface {
 	// Compute generates the statistic on a set of scores immediately.
 	Compute(ss []score.Score) (interface{}, error)
 	// Step takes a score and, if possible, includes it in the running computation
 	// For example, Average.Step() will add a score to the running total
-	// We won't need this for Average really, but it could be useful in other types of computations
+	// ScoreKeeper steps each score as it is stored, so GetStats doesn't recompute from the store.
 	Step(s score.Score) error
 	// Report returns the result of the running computation.
 	Report() (interface{}, error)
@@ -28,6 +31,96 @@ type Average struct {
 
 var ErrTypeInvalid = errors.New("invalid type")
 var ErrNoData = errors.New("no data to report")
+var ErrUnknownStat = errors.New("unknown stat")
+
+// Constructor returns a fresh Stat, ready to Step.
+type Constructor func() Stat
+
+// Factory names the Stats available to ScoreKeeper, like score.ScoreFactory does for Scores.
+type Factory map[string]Constructor
+
+// Defaults returns a Factory of the Stats built into this package.
+func Defaults() Factory {
+	return Factory{
+		"avg":             func() Stat { return &Average{} },
+		"min":             func() Stat { return &Min{} },
+		"max":             func() Stat { return &Max{} },
+		"count":           func() Stat { return &Count{} },
+		"sum":             func() Stat { return &Sum{} },
+		"variance":        func() Stat { return &Variance{} },
+		"stddev":          func() Stat { return &StdDev{} },
+		"median":          func() Stat { return NewMedian() },
+		"p50":             func() Stat { return &Percentile{P: 50} },
+		"p90":             func() Stat { return &Percentile{P: 90} },
+		"p99":             func() Stat { return &Percentile{P: 99} },
+		"approx_p50":      func() Stat { return &ApproxPercentile{P: 50} },
+		"approx_p90":      func() Stat { return &ApproxPercentile{P: 90} },
+		"approx_p99":      func() Stat { return &ApproxPercentile{P: 99} },
+		"histogram":       func() Stat { return &Histogram{Bounds: ExponentialBuckets(1, 2, 20)} },
+		"trimmed_mean":    func() Stat { return &TrimmedMean{Fraction: defaultFraction} },
+		"winsorized_mean": func() Stat { return &WinsorizedMean{Fraction: defaultFraction} },
+	}
+}
+
+// Create returns a fresh Stat by name from the factory.
+// Some names don't need to be registered:
+//   - percentiles like "p75" or "p99.9"
+//   - approximate percentiles like "approx_p75", see ApproxPercentile
+//   - any stat over the last N scores, like "avg_last100"
+//   - any stat over the last stretch of time, like "avg_5m" or "p99_1h30m"
+func Create(f Factory, name string) (Stat, error) {
+	if constructor, ok := f[name]; ok {
+		return constructor(), nil
+	}
+
+	if strings.HasPrefix(name, "p") {
+		p, err := strconv.ParseFloat(name[1:], 64)
+		if err == nil && p >= 0 && p <= 100 {
+			return &Percentile{P: p}, nil
+		}
+	}
+
+	if strings.HasPrefix(name, "approx_p") {
+		p, err := strconv.ParseFloat(name[len("approx_p"):], 64)
+		if err == nil && p >= 0 && p <= 100 {
+			return &ApproxPercentile{P: p}, nil
+		}
+	}
+
+	if i := strings.LastIndex(name, "_"); i > 0 {
+		if st, ok := window(f, name[:i], name[i+1:]); ok {
+			return st, nil
+		}
+	}
+
+	return nil, ErrUnknownStat
+}
+
+// window creates the stat named base over the window named by suffix, like "last100" or "5m".
+func window(f Factory, base, suffix string) (Stat, bool) {
+	if _, err := Create(f, base); err != nil {
+		return nil, false
+	}
+	of := func() Stat {
+		// base was created once already, so it can't fail
+		st, _ := Create(f, base)
+		return st
+	}
+
+	if strings.HasPrefix(suffix, "last") {
+		n, err := strconv.Atoi(suffix[len("last"):])
+		if err != nil || n < 1 {
+			return nil, false
+		}
+		return &LastN{N: n, Of: of}, true
+	}
+
+	d, err := time.ParseDuration(suffix)
+	if err != nil || d <= 0 {
+		return nil, false
+	}
+	return &Within{D: d, Of: of}, true
+}
 
 // Compute a floating point average from a list of scores with float64 values.
 func (a *Average) Compute(ss []score.Score) (interface{}, error) {
@@ -119,3 +119,67 @@ func TestAverage(t *testing.T) {
 		}
 	}
 }
+
+func TestCreate(t *testing.T) {
+	type testCase struct {
+		name string
+		err  error
+	}
+
+	testCases := []testCase{
+		{name: "avg"},
+		{name: "min"},
+		{name: "max"},
+		{name: "count"},
+		{name: "sum"},
+		{name: "variance"},
+		{name: "stddev"},
+		{name: "median"},
+		{name: "p0"},
+		{name: "p99"},
+		{name: "p99.9"},
+		{name: "p100"},
+		{name: "p101", err: ErrUnknownStat},
+		{name: "p", err: ErrUnknownStat},
+		{name: "pnan", err: ErrUnknownStat},
+		{name: "trimmed_mean"},
+		{name: "winsorized_mean"},
+		{name: "trimmed_mean_last100"},
+		{name: "approx_p50"},
+		{name: "approx_p75"},
+		{name: "approx_p101", err: ErrUnknownStat},
+		{name: "approx_p99_last100"},
+		{name: "mode", err: ErrUnknownStat},
+		{name: "", err: ErrUnknownStat},
+		{name: "avg_last100"},
+		{name: "p99_last10"},
+		{name: "avg_5m"},
+		{name: "p99.9_1h30m"},
+		{name: "avg_5m_last10"},
+		{name: "avg_last0", err: ErrUnknownStat},
+		{name: "avg_last", err: ErrUnknownStat},
+		{name: "avg_-5m", err: ErrUnknownStat},
+		{name: "avg_0s", err: ErrUnknownStat},
+		{name: "mode_5m", err: ErrUnknownStat},
+		{name: "_5m", err: ErrUnknownStat},
+		{name: "avg_", err: ErrUnknownStat},
+	}
+
+	f := Defaults()
+	for _, tc := range testCases {
+		if _, err := Create(f, tc.name); tc.err != err {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestCreateRegistered(t *testing.T) {
+	f := Factory{"mode": func() Stat { return &Average{} }}
+
+	if _, err := Create(f, "mode"); err != nil {
+		t.Errorf("Expected registered stat to be created but got '%v'", err)
+	}
+	if _, err := Create(f, "avg"); err != ErrUnknownStat {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrUnknownStat, err)
+	}
+}
@@ -0,0 +1,249 @@
+package stat
+
+import (
+	"math"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// Min is a Stat that finds the smallest of scores with float64 values.
+type Min struct {
+	n   float64
+	min float64
+}
+
+// Compute the smallest value in a list of scores.
+func (m *Min) Compute(ss []score.Score) (interface{}, error) {
+	c := Min{}
+	for _, s := range ss {
+		if err := c.Step(s); err != nil {
+			return float64(0), err
+		}
+	}
+
+	return c.Report()
+}
+
+// Step keeps the score if it is the smallest so far.
+func (m *Min) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	if m.n < 1 || v < m.min {
+		m.min = v
+	}
+	m.n++
+	return nil
+}
+
+// Report the smallest value so far.
+func (m *Min) Report() (interface{}, error) {
+	if m.n < float64(1) {
+		return float64(0), ErrNoData
+	}
+
+	return m.min, nil
+}
+
+// Max is a Stat that finds the largest of scores with float64 values.
+type Max struct {
+	n   float64
+	max float64
+}
+
+// Compute the largest value in a list of scores.
+func (m *Max) Compute(ss []score.Score) (interface{}, error) {
+	c := Max{}
+	for _, s := range ss {
+		if err := c.Step(s); err != nil {
+			return float64(0), err
+		}
+	}
+
+	return c.Report()
+}
+
+// Step keeps the score if it is the largest so far.
+func (m *Max) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	if m.n < 1 || v > m.max {
+		m.max = v
+	}
+	m.n++
+	return nil
+}
+
+// Report the largest value so far.
+func (m *Max) Report() (interface{}, error) {
+	if m.n < float64(1) {
+		return float64(0), ErrNoData
+	}
+
+	return m.max, nil
+}
+
+// Count is a Stat that counts scores with float64 values.
+type Count struct {
+	n float64
+}
+
+// Compute the number of scores in a list.
+func (c *Count) Compute(ss []score.Score) (interface{}, error) {
+	n := Count{}
+	for _, s := range ss {
+		if err := n.Step(s); err != nil {
+			return float64(0), err
+		}
+	}
+
+	return n.Report()
+}
+
+// Step counts the score.
+func (c *Count) Step(s score.Score) error {
+	if _, ok := s.Value().(float64); !ok {
+		return ErrTypeInvalid
+	}
+
+	c.n++
+	return nil
+}
+
+// Report the number of scores so far.
+func (c *Count) Report() (interface{}, error) {
+	if c.n < float64(1) {
+		return float64(0), ErrNoData
+	}
+
+	return c.n, nil
+}
+
+// Sum is a Stat that adds up scores with float64 values.
+// It uses Neumaier's compensated summation, so small values aren't lost beside large ones.
+type Sum struct {
+	n   float64
+	sum float64
+	// c is the low-order part lost from sum so far
+	c float64
+}
+
+// Compute the sum of a list of scores.
+func (m *Sum) Compute(ss []score.Score) (interface{}, error) {
+	c := Sum{}
+	for _, s := range ss {
+		if err := c.Step(s); err != nil {
+			return float64(0), err
+		}
+	}
+
+	return c.Report()
+}
+
+// Step adds a score to the running sum.
+func (m *Sum) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	t := m.sum + v
+	if math.Abs(m.sum) >= math.Abs(v) {
+		m.c += (m.sum - t) + v
+	} else {
+		m.c += (v - t) + m.sum
+	}
+	m.sum = t
+	m.n++
+	return nil
+}
+
+// Report the sum so far.
+func (m *Sum) Report() (interface{}, error) {
+	if m.n < float64(1) {
+		return float64(0), ErrNoData
+	}
+
+	return m.sum + m.c, nil
+}
+
+// Variance is a Stat that computes the population variance of scores with float64 values.
+// It uses Welford's method, so the running computation stays accurate for large values.
+type Variance struct {
+	n    float64
+	mean float64
+	m2   float64
+}
+
+// Compute the variance of a list of scores.
+func (d *Variance) Compute(ss []score.Score) (interface{}, error) {
+	c := Variance{}
+	for _, s := range ss {
+		if err := c.Step(s); err != nil {
+			return float64(0), err
+		}
+	}
+
+	return c.Report()
+}
+
+// Step adds a score to the running mean and sum of squared differences.
+func (d *Variance) Step(s score.Score) error {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return ErrTypeInvalid
+	}
+
+	d.n++
+	delta := v - d.mean
+	d.mean += delta / d.n
+	d.m2 += delta * (v - d.mean)
+	return nil
+}
+
+// Report the variance so far.
+func (d *Variance) Report() (interface{}, error) {
+	if d.n < float64(1) {
+		return float64(0), ErrNoData
+	}
+
+	return d.m2 / d.n, nil
+}
+
+// StdDev is a Stat that computes the population standard deviation of scores with float64 values,
+// as the square root of their Variance.
+type StdDev struct {
+	v Variance
+}
+
+// Compute the standard deviation of a list of scores.
+func (d *StdDev) Compute(ss []score.Score) (interface{}, error) {
+	c := StdDev{}
+	for _, s := range ss {
+		if err := c.Step(s); err != nil {
+			return float64(0), err
+		}
+	}
+
+	return c.Report()
+}
+
+// Step adds a score to the running variance.
+func (d *StdDev) Step(s score.Score) error {
+	return d.v.Step(s)
+}
+
+// Report the standard deviation so far.
+func (d *StdDev) Report() (interface{}, error) {
+	v, err := d.v.Report()
+	if err != nil {
+		return float64(0), err
+	}
+
+	return math.Sqrt(v.(float64)), nil
+}
@@ -0,0 +1,230 @@
+package stat
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+func TestSummary(t *testing.T) {
+	type testCase struct {
+		name string
+		ss   []score.Score
+		min  float64
+		max  float64
+		n    float64
+		sum  float64
+		v    float64
+		sd   float64
+		err  error
+	}
+
+	testCases := []testCase{
+		{
+			name: "provided",
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(100)},
+				&score.TestScore{TValue: float64(200)},
+			},
+			min: 100,
+			max: 200,
+			n:   2,
+			sum: 300,
+			v:   2500,
+			sd:  50,
+		},
+		{
+			name: "empty",
+			ss:   []score.Score{},
+			err:  ErrNoData,
+		},
+		{
+			name: "one",
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(7)},
+			},
+			min: 7,
+			max: 7,
+			n:   1,
+			sum: 7,
+			sd:  0,
+		},
+		{
+			name: "negative",
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(-1)},
+				&score.TestScore{TValue: float64(-3)},
+				&score.TestScore{TValue: float64(-2)},
+			},
+			min: -3,
+			max: -1,
+			n:   3,
+			sum: -6,
+			v:   float64(2) / 3,
+			sd:  math.Sqrt(float64(2) / 3),
+		},
+		{
+			name: "textbook",
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(2)},
+				&score.TestScore{TValue: float64(4)},
+				&score.TestScore{TValue: float64(4)},
+				&score.TestScore{TValue: float64(4)},
+				&score.TestScore{TValue: float64(5)},
+				&score.TestScore{TValue: float64(5)},
+				&score.TestScore{TValue: float64(7)},
+				&score.TestScore{TValue: float64(9)},
+			},
+			min: 2,
+			max: 9,
+			n:   8,
+			sum: 40,
+			v:   4,
+			sd:  2,
+		},
+	}
+
+	for _, tc := range testCases {
+		stats := map[string]Stat{
+			"min":      &Min{},
+			"max":      &Max{},
+			"count":    &Count{},
+			"sum":      &Sum{},
+			"variance": &Variance{},
+			"stddev":   &StdDev{},
+		}
+		want := map[string]float64{
+			"min":      tc.min,
+			"max":      tc.max,
+			"count":    tc.n,
+			"sum":      tc.sum,
+			"variance": tc.v,
+			"stddev":   tc.sd,
+		}
+
+		for name, st := range stats {
+			for _, s := range tc.ss {
+				if err := st.Step(s); err != nil {
+					t.Errorf("[%s] %s: Expected no error but got '%v'", tc.name, name, err)
+				}
+			}
+
+			res, err := st.Report()
+			if expected, got := tc.err, err; expected != got {
+				t.Errorf("[%s] %s: Expected error to be '%v' but got '%v'", tc.name, name, expected, got)
+			}
+			if expected, got := want[name], res; expected != got {
+				t.Errorf("[%s] %s: Expected %f but got %f", tc.name, name, expected, got)
+			}
+
+			res2, err := st.Compute(tc.ss)
+			if expected, got := tc.err, err; expected != got {
+				t.Errorf("[%s] %s Compute: Expected error to be '%v' but got '%v'", tc.name, name, expected, got)
+			}
+			if expected, got := want[name], res2; expected != got {
+				t.Errorf("[%s] %s Compute: Expected %f but got %f", tc.name, name, expected, got)
+			}
+		}
+	}
+}
+
+func TestSummaryInvalid(t *testing.T) {
+	ss := []score.Score{
+		&score.TestScore{TValue: float64(1)},
+		&badScore{},
+	}
+
+	stats := map[string]Stat{
+		"min":      &Min{},
+		"max":      &Max{},
+		"count":    &Count{},
+		"sum":      &Sum{},
+		"variance": &Variance{},
+		"stddev":   &StdDev{},
+	}
+	for name, st := range stats {
+		if expected, got := ErrTypeInvalid, st.Step(ss[1]); expected != got {
+			t.Errorf("%s: Expected error to be '%v' but got '%v'", name, expected, got)
+		}
+		if _, err := st.Compute(ss); err != ErrTypeInvalid {
+			t.Errorf("%s Compute: Expected error to be '%v' but got '%v'", name, ErrTypeInvalid, err)
+		}
+	}
+}
+
+// TestSummaryStable checks the running stats stay accurate, to a part in a million, where the textbook formulas don't.
+func TestSummaryStable(t *testing.T) {
+	type testCase struct {
+		name   string
+		st     func() Stat
+		values []float64
+		want   float64
+	}
+
+	testCases := []testCase{
+		{
+			// sum(x²)/n - mean² loses every digit of this
+			name:   "variance with a large offset",
+			st:     func() Stat { return &Variance{} },
+			values: []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16},
+			want:   22.5,
+		},
+		{
+			name:   "stddev with a large offset",
+			st:     func() Stat { return &StdDev{} },
+			values: []float64{1e9 + 2, 1e9 + 4, 1e9 + 4, 1e9 + 4, 1e9 + 5, 1e9 + 5, 1e9 + 7, 1e9 + 9},
+			want:   2,
+		},
+		{
+			name:   "sum of small values beside large ones",
+			st:     func() Stat { return &Sum{} },
+			values: []float64{1, 1e100, 1, -1e100},
+			want:   2,
+		},
+		{
+			name:   "sum of many tenths",
+			st:     func() Stat { return &Sum{} },
+			values: repeat(0.1, 1000000),
+			want:   100000,
+		},
+	}
+
+	for _, tc := range testCases {
+		var ss []score.Score
+		for _, v := range tc.values {
+			ss = append(ss, &score.TestScore{TValue: v})
+		}
+
+		st := tc.st()
+		for _, s := range ss {
+			if err := st.Step(s); err != nil {
+				t.Fatalf("[%s] Expected no error but got '%v'", tc.name, err)
+			}
+		}
+		res, err := st.Report()
+		if err != nil {
+			t.Fatalf("[%s] Expected no error but got '%v'", tc.name, err)
+		}
+		if expected, got := tc.want, res.(float64); math.Abs(expected-got) > 1e-6*math.Max(1, math.Abs(expected)) {
+			t.Errorf("[%s] Expected %v but got %v", tc.name, expected, got)
+		}
+
+		res2, err := tc.st().Compute(ss)
+		if err != nil {
+			t.Fatalf("[%s] Compute: Expected no error but got '%v'", tc.name, err)
+		}
+		if expected, got := res, res2; expected != got {
+			t.Errorf("[%s] Compute: Expected %v, as Step gave, but got %v", tc.name, expected, got)
+		}
+	}
+}
+
+// repeat returns n copies of v.
+func repeat(v float64, n int) []float64 {
+	vs := make([]float64, n)
+	for i := range vs {
+		vs[i] = v
+	}
+	return vs
+}
@@ -0,0 +1,144 @@
+package stat
+
+import (
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// LastN is a Stat over only the latest N scores it is given,
+// like "the average of the last 100 attempts".
+type LastN struct {
+	// N is the number of scores in the window.
+	N int
+	// Of makes the Stat computed over the window.
+	Of Constructor
+
+	// ss is a ring of the latest scores, oldest at next once full
+	ss   []score.Score
+	next int
+}
+
+// Compute the stat over the last N of a list of scores.
+func (l *LastN) Compute(ss []score.Score) (interface{}, error) {
+	if len(ss) > l.N {
+		ss = ss[len(ss)-l.N:]
+	}
+
+	return l.Of().Compute(ss)
+}
+
+// Step adds a score to the window, pushing out the oldest once it is full.
+func (l *LastN) Step(s score.Score) error {
+	if _, ok := s.Value().(float64); !ok {
+		return ErrTypeInvalid
+	}
+	if l.N < 1 {
+		return nil
+	}
+
+	if len(l.ss) < l.N {
+		l.ss = append(l.ss, s)
+		return nil
+	}
+
+	l.ss[l.next] = s
+	l.next = (l.next + 1) % l.N
+	return nil
+}
+
+// Report the stat over the scores in the window.
+func (l *LastN) Report() (interface{}, error) {
+	window := make([]score.Score, 0, len(l.ss))
+	window = append(window, l.ss[l.next:]...)
+	window = append(window, l.ss[:l.next]...)
+
+	return l.Of().Compute(window)
+}
+
+// Timed is a Stat that depends on the current time, like Within.
+// ScoreKeeper gives Timed stats its own clock.
+type Timed interface {
+	SetNow(now func() time.Time)
+}
+
+// Within is a Stat over only the scores for actions in the last D,
+// like "the average over the last 5 minutes". See score.WhenOf for when an action happened.
+type Within struct {
+	// D is how far back the window reaches from now.
+	D time.Duration
+	// Of makes the Stat computed over the window.
+	Of Constructor
+
+	now func() time.Time
+	// ss in the order they were stepped
+	ss []score.Score
+}
+
+// SetNow sets the clock the window ends at. The default is time.Now.
+func (w *Within) SetNow(now func() time.Time) {
+	w.now = now
+}
+
+// cutoff is the time the window starts at.
+func (w *Within) cutoff() time.Time {
+	now := w.now
+	if now == nil {
+		now = time.Now
+	}
+
+	return now().Add(-w.D)
+}
+
+// inWindow returns the scores in ss that happened at or after cutoff.
+func inWindow(ss []score.Score, cutoff time.Time) []score.Score {
+	window := make([]score.Score, 0, len(ss))
+	for _, s := range ss {
+		if !score.WhenOf(s).Before(cutoff) {
+			window = append(window, s)
+		}
+	}
+
+	return window
+}
+
+// Compute the stat over the scores in a list that happened in the last D.
+func (w *Within) Compute(ss []score.Score) (interface{}, error) {
+	return w.Of().Compute(inWindow(ss, w.cutoff()))
+}
+
+// Step adds a score to the window, expiring the scores that have fallen out of it.
+func (w *Within) Step(s score.Score) error {
+	if _, ok := s.Value().(float64); !ok {
+		return ErrTypeInvalid
+	}
+
+	w.ss = append(w.ss, s)
+	w.expire()
+	return nil
+}
+
+// Report the stat over the scores still in the window.
+func (w *Within) Report() (interface{}, error) {
+	w.expire()
+	return w.Compute(w.ss)
+}
+
+// expire the scores that have fallen out of the window, wherever they were stepped,
+// so one that happened later than the rest doesn't hold the others in.
+func (w *Within) expire() {
+	cutoff := w.cutoff()
+
+	kept := w.ss[:0]
+	for _, s := range w.ss {
+		if !score.WhenOf(s).Before(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	// let the expired scores be collected
+	for i := len(kept); i < len(w.ss); i++ {
+		w.ss[i] = nil
+	}
+
+	w.ss = kept
+}
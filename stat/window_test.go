@@ -0,0 +1,225 @@
+package stat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+func TestLastN(t *testing.T) {
+	type testCase struct {
+		name string
+		n    int
+		ss   []score.Score
+		avg  float64
+		err  error
+	}
+
+	testCases := []testCase{
+		{
+			name: "partial",
+			n:    3,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(100)},
+				&score.TestScore{TValue: float64(200)},
+			},
+			avg: 150,
+		},
+		{
+			name: "full",
+			n:    2,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(100)},
+				&score.TestScore{TValue: float64(200)},
+			},
+			avg: 150,
+		},
+		{
+			name: "wrapped",
+			n:    2,
+			ss: []score.Score{
+				&score.TestScore{TValue: float64(100)},
+				&score.TestScore{TValue: float64(200)},
+				&score.TestScore{TValue: float64(300)},
+				&score.TestScore{TValue: float64(400)},
+				&score.TestScore{TValue: float64(500)},
+			},
+			avg: 450,
+		},
+		{
+			name: "empty",
+			n:    2,
+			ss:   []score.Score{},
+			err:  ErrNoData,
+		},
+	}
+
+	for _, tc := range testCases {
+		l := &LastN{N: tc.n, Of: func() Stat { return &Average{} }}
+		for _, s := range tc.ss {
+			if err := l.Step(s); err != nil {
+				t.Errorf("[%s] Expected no error but got '%v'", tc.name, err)
+			}
+		}
+
+		res, err := l.Report()
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if err == nil {
+			if expected, got := tc.avg, res; expected != got {
+				t.Errorf("[%s] Expected %f but got %f", tc.name, expected, got)
+			}
+		}
+
+		res2, err := l.Compute(tc.ss)
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Compute: Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if err == nil {
+			if expected, got := tc.avg, res2; expected != got {
+				t.Errorf("[%s] Compute: Expected %f but got %f", tc.name, expected, got)
+			}
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	base := time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int, v float64) score.Score {
+		return &score.TestScore{
+			TValue: v,
+			Meta:   score.Meta{Recorded: base.Add(time.Duration(minutes) * time.Minute)},
+		}
+	}
+
+	type testCase struct {
+		name string
+		now  time.Time
+		ss   []score.Score
+		avg  float64
+		err  error
+	}
+
+	testCases := []testCase{
+		{
+			name: "all recent",
+			now:  base.Add(5 * time.Minute),
+			ss:   []score.Score{at(1, 100), at(2, 200)},
+			avg:  150,
+		},
+		{
+			name: "some expired",
+			now:  base.Add(10 * time.Minute),
+			ss:   []score.Score{at(1, 100), at(2, 200), at(6, 300), at(9, 400)},
+			avg:  350,
+		},
+		{
+			name: "edge of window",
+			now:  base.Add(10 * time.Minute),
+			ss:   []score.Score{at(4, 100), at(5, 200)},
+			avg:  200,
+		},
+		{
+			name: "happened earlier",
+			now:  base.Add(10 * time.Minute),
+			ss: []score.Score{
+				at(6, 100),
+				&score.TestScore{TValue: float64(900), Meta: score.Meta{At: base, Recorded: base.Add(9 * time.Minute)}},
+			},
+			avg: 100,
+		},
+		{
+			name: "all expired",
+			now:  base.Add(time.Hour),
+			ss:   []score.Score{at(1, 100), at(2, 200)},
+			err:  ErrNoData,
+		},
+	}
+
+	for _, tc := range testCases {
+		now := tc.now
+		w := &Within{D: 5 * time.Minute, Of: func() Stat { return &Average{} }}
+		w.SetNow(func() time.Time { return now })
+
+		for _, s := range tc.ss {
+			if err := w.Step(s); err != nil {
+				t.Errorf("[%s] Expected no error but got '%v'", tc.name, err)
+			}
+		}
+
+		res, err := w.Report()
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if err == nil {
+			if expected, got := tc.avg, res; expected != got {
+				t.Errorf("[%s] Expected %f but got %f", tc.name, expected, got)
+			}
+		}
+
+		res2, err := w.Compute(tc.ss)
+		if expected, got := tc.err, err; expected != got {
+			t.Errorf("[%s] Compute: Expected error to be '%v' but got '%v'", tc.name, expected, got)
+		}
+		if err == nil {
+			if expected, got := tc.avg, res2; expected != got {
+				t.Errorf("[%s] Compute: Expected %f but got %f", tc.name, expected, got)
+			}
+		}
+	}
+}
+
+func TestWithinExpires(t *testing.T) {
+	base := time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC)
+	now := base
+
+	w := &Within{D: time.Minute, Of: func() Stat { return &Average{} }}
+	w.SetNow(func() time.Time { return now })
+
+	for i := 0; i < 100; i++ {
+		now = base.Add(time.Duration(i) * time.Second)
+		s := &score.TestScore{TValue: float64(i), Meta: score.Meta{Recorded: now}}
+		if err := w.Step(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 39..99 are within a minute of 99s
+	if expected, got := 61, len(w.ss); expected != got {
+		t.Errorf("Expected %d scores in the window but got %d", expected, got)
+	}
+	res, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := float64(69), res; expected != got {
+		t.Errorf("Expected %f but got %f", expected, got)
+	}
+}
+
+func TestWithinExpiresBehindFuture(t *testing.T) {
+	base := time.Date(2022, 3, 4, 12, 0, 0, 0, time.UTC)
+	now := base
+
+	w := &Within{D: time.Minute, Of: func() Stat { return &Average{} }}
+	w.SetNow(func() time.Time { return now })
+
+	// a score that says it happened in an hour doesn't keep the ones stepped after it in the window
+	if err := w.Step(&score.TestScore{TValue: float64(1000), Meta: score.Meta{At: base.Add(time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		now = base.Add(time.Duration(i) * time.Second)
+		s := &score.TestScore{TValue: float64(i), Meta: score.Meta{Recorded: now}}
+		if err := w.Step(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the future score and 39..99
+	if expected, got := 62, len(w.ss); expected != got {
+		t.Errorf("Expected %d scores in the window but got %d", expected, got)
+	}
+}
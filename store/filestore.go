@@ -0,0 +1,362 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// SyncPolicy decides when a FileStore flushes its log to disk.
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes after every Store. Nothing acknowledged is lost in a crash.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes periodically. A crash can lose the scores of the last interval.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+// record is one line of the log.
+type record struct {
+	Type     string     `json:"type"`
+	Name     string     `json:"name"`
+	Value    float64    `json:"value"`
+	Cohort   string     `json:"cohort,omitempty"`
+	Player   string     `json:"player,omitempty"`
+	Outlier  bool       `json:"outlier,omitempty"`
+	At       *time.Time `json:"at,omitempty"`
+	Recorded *time.Time `json:"recorded,omitempty"`
+}
+
+// newRecord keeps score s, which must have a float64 value.
+func newRecord(s score.Score) (record, error) {
+	v, ok := s.Value().(float64)
+	if !ok {
+		return record{}, fmt.Errorf("%w: got %T", ErrValueType, s.Value())
+	}
+
+	rec := record{Type: s.Type(), Name: s.Name(), Value: v}
+	if m := score.MetadataOf(s); m != nil {
+		rec.Cohort = m.Cohort
+		rec.Player = m.Player
+		rec.Outlier = m.Outlier
+		if !m.At.IsZero() {
+			at := m.At
+			rec.At = &at
+		}
+		if !m.Recorded.IsZero() {
+			recorded := m.Recorded
+			rec.Recorded = &recorded
+		}
+	}
+
+	return rec, nil
+}
+
+// meta returns the Meta kept in the record.
+func (rec record) meta() score.Meta {
+	m := score.Meta{Cohort: rec.Cohort, Player: rec.Player, Outlier: rec.Outlier}
+	if rec.At != nil {
+		m.At = *rec.At
+	}
+	if rec.Recorded != nil {
+		m.Recorded = *rec.Recorded
+	}
+
+	return m
+}
+
+// FileStore keeps scores in an append-only log of json lines, one record per score.
+// Opening a FileStore replays its log, so scores survive restarts without a database.
+// It is safe for concurrent use.
+type FileStore struct {
+	mu   sync.Mutex
+	f    *os.File
+	path string
+
+	policy   SyncPolicy
+	interval time.Duration
+	// dirty is set when there are writes not yet flushed
+	dirty bool
+	quit  chan struct{}
+	done  chan struct{}
+	// stopSync stops the SyncInterval flusher once, however many times Close is called
+	stopSync sync.Once
+
+	// records by scoreType and name, replayed from the log
+	records map[string]map[string][]record
+}
+
+// FileOption configures a FileStore in OpenFileStore.
+type FileOption func(fs *FileStore)
+
+// WithSync sets the SyncPolicy of the store. The default is SyncAlways.
+func WithSync(p SyncPolicy) FileOption {
+	return func(fs *FileStore) {
+		fs.policy = p
+	}
+}
+
+// WithSyncInterval flushes the log every d, using SyncInterval.
+func WithSyncInterval(d time.Duration) FileOption {
+	return func(fs *FileStore) {
+		fs.policy = SyncInterval
+		fs.interval = d
+	}
+}
+
+const defaultSyncInterval = time.Second
+
+var (
+	ErrCorruptLog = errors.New("corrupt score log")
+	ErrValueType  = errors.New("score value must be a float64")
+	ErrClosed     = errors.New("store closed")
+)
+
+// OpenFileStore opens the log at path, creating it if it doesn't exist, and replays it.
+// If the last record was torn by a crash mid-write, it is truncated away.
+// A bad record anywhere else returns ErrCorruptLog.
+func OpenFileStore(path string, opts ...FileOption) (*FileStore, error) {
+	fs := &FileStore{
+		path:     path,
+		interval: defaultSyncInterval,
+		records:  map[string]map[string][]record{},
+	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open score log: %w", err)
+	}
+	fs.f = f
+
+	if err := fs.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if fs.policy == SyncInterval {
+		fs.quit = make(chan struct{})
+		fs.done = make(chan struct{})
+		go fs.syncEvery(fs.interval)
+	}
+
+	return fs, nil
+}
+
+// replay the log into memory, truncating a torn final record.
+func (fs *FileStore) replay() error {
+	if _, err := fs.f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read score log: %w", err)
+	}
+
+	r := bufio.NewReader(fs.f)
+
+	// good is the offset just past the last good record
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				return nil
+			}
+			// a record without its newline was torn mid-write
+			return fs.truncate(good)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read score log: %w", err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			// a bad final record was torn, anything earlier is corruption
+			if _, peekErr := r.Peek(1); peekErr == io.EOF {
+				return fs.truncate(good)
+			}
+			return fmt.Errorf("%w at offset %d: %v", ErrCorruptLog, good, err)
+		}
+
+		fs.add(rec)
+		good += int64(len(line))
+	}
+}
+
+// truncate the log at offset, discarding a torn record.
+func (fs *FileStore) truncate(offset int64) error {
+	if err := fs.f.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate torn record: %w", err)
+	}
+	if err := fs.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync score log: %w", err)
+	}
+
+	return nil
+}
+
+// add a record to memory.
+func (fs *FileStore) add(rec record) {
+	if fs.records[rec.Type] == nil {
+		fs.records[rec.Type] = map[string][]record{}
+	}
+
+	fs.records[rec.Type][rec.Name] = append(fs.records[rec.Type][rec.Name], rec)
+}
+
+// syncEvery flushes the log every d until the store is closed.
+func (fs *FileStore) syncEvery(d time.Duration) {
+	defer close(fs.done)
+
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-fs.quit:
+			return
+		case <-t.C:
+			fs.mu.Lock()
+			if fs.dirty && fs.f != nil {
+				if err := fs.f.Sync(); err == nil {
+					fs.dirty = false
+				}
+			}
+			fs.mu.Unlock()
+		}
+	}
+}
+
+// Store appends score `s` to the log.
+func (fs *FileStore) Store(ctx context.Context, s score.Score) error {
+	return fs.StoreBatch(ctx, []score.Score{s})
+}
+
+// StoreBatch appends many scores to the log with one write, and one flush for SyncAlways.
+func (fs *FileStore) StoreBatch(ctx context.Context, ss []score.Score) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	recs := make([]record, 0, len(ss))
+	var b []byte
+	for _, s := range ss {
+		rec, err := newRecord(s)
+		if err != nil {
+			return err
+		}
+
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode score: %w", err)
+		}
+
+		recs = append(recs, rec)
+		b = append(b, line...)
+		b = append(b, '\n')
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		return ErrClosed
+	}
+
+	// the end of the log, to truncate a partial write back to
+	info, err := fs.f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to append score: %w", err)
+	}
+
+	if _, err := fs.f.Write(b); err != nil {
+		// a partial record left behind would be torn in the middle of the log once another is appended
+		if truncErr := fs.truncate(info.Size()); truncErr != nil {
+			return fmt.Errorf("failed to append score: %w (and %v)", err, truncErr)
+		}
+		return fmt.Errorf("failed to append score: %w", err)
+	}
+
+	switch fs.policy {
+	case SyncAlways:
+		if err := fs.f.Sync(); err != nil {
+			return fmt.Errorf("failed to sync score log: %w", err)
+		}
+	case SyncInterval:
+		fs.dirty = true
+	}
+
+	for _, rec := range recs {
+		fs.add(rec)
+	}
+	return nil
+}
+
+// Retrieve Scores matching the query from the replayed log by name.
+func (fs *FileStore) Retrieve(ctx context.Context, f score.ScoreFactory, scoreType string, q Query) (map[string][]score.Score, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		return nil, ErrClosed
+	}
+
+	ret := map[string][]score.Score{}
+	for name, records := range fs.records[scoreType] {
+		for _, rec := range records {
+			meta := rec.meta()
+			if !q.matches(meta.Cohort, meta.When()) {
+				continue
+			}
+
+			s, err := newScore(f, scoreType, name, rec.Value, meta)
+			if err != nil {
+				return nil, err
+			}
+			ret[name] = append(ret[name], s)
+		}
+	}
+
+	return ret, nil
+}
+
+// Close flushes and closes the log. Closing it again returns ErrClosed.
+func (fs *FileStore) Close() error {
+	fs.stopSync.Do(func() {
+		if fs.quit != nil {
+			close(fs.quit)
+			<-fs.done
+		}
+	})
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		return ErrClosed
+	}
+
+	syncErr := fs.f.Sync()
+	closeErr := fs.f.Close()
+	fs.f = nil
+
+	if syncErr != nil {
+		return fmt.Errorf("failed to sync score log: %w", syncErr)
+	}
+	return closeErr
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+func TestFileStoreBehaviour(t *testing.T) {
+	for _, p := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		fs, err := OpenFileStore(filepath.Join(t.TempDir(), "scores.log"), WithSync(p))
+		if err != nil {
+			t.Fatalf("failed to open filestore: %v", err)
+		}
+
+		testScoreStore(t, fs)
+		testBatchStore(t, fs, &intScore{})
+
+		if err := fs.Close(); err != nil {
+			t.Errorf("failed to close filestore: %v", err)
+		}
+	}
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.log")
+	factory := score.ScoreFactory{"test": score.NewTestScore}
+
+	for i := 0; i < 3; i++ {
+		fs, err := OpenFileStore(path, WithSyncInterval(time.Millisecond))
+		if err != nil {
+			t.Fatalf("failed to open filestore: %v", err)
+		}
+
+		if err := fs.Store(context.Background(), &score.TestScore{TName: "a", TValue: float64(i)}); err != nil {
+			t.Fatalf("failed to store: %v", err)
+		}
+
+		got, err := fs.Retrieve(context.Background(), factory, "test", Query{})
+		if err != nil {
+			t.Fatalf("failed to retrieve: %v", err)
+		}
+		if e, g := i+1, len(got["a"]); e != g {
+			t.Errorf("expected %d scores after reopening but got %d", e, g)
+		}
+		if e, g := float64(i), got["a"][i].Value(); e != g {
+			t.Errorf("expected the newest score to be %v but got %v", e, g)
+		}
+
+		if err := fs.Close(); err != nil {
+			t.Fatalf("failed to close filestore: %v", err)
+		}
+	}
+}
+
+func TestFileStoreTorn(t *testing.T) {
+	good := `{"type":"test","name":"a","value":1}` + "\n" + `{"type":"test","name":"a","value":2}` + "\n"
+	factory := score.ScoreFactory{"test": score.NewTestScore}
+
+	type testCase struct {
+		name string
+		log  string
+		n    int
+		err  error
+	}
+	testCases := []testCase{
+		{
+			name: "clean",
+			log:  good,
+			n:    2,
+		},
+		{
+			name: "empty",
+			log:  "",
+			n:    0,
+		},
+		{
+			name: "torn",
+			log:  good + `{"type":"test","na`,
+			n:    2,
+		},
+		{
+			name: "torn with newline",
+			log:  good + "{\"type\":\"te\x00\x00\n",
+			n:    2,
+		},
+		{
+			name: "corrupt middle",
+			log:  `{"type":"test","na` + "\n" + good,
+			err:  ErrCorruptLog,
+		},
+	}
+
+	for _, tc := range testCases {
+		path := filepath.Join(t.TempDir(), "scores.log")
+		if err := ioutil.WriteFile(path, []byte(tc.log), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fs, err := OpenFileStore(path)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("[%s] expected error '%v' but got '%v'", tc.name, tc.err, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		got, err := fs.Retrieve(context.Background(), factory, "test", Query{})
+		if err != nil {
+			t.Fatalf("[%s] failed to retrieve: %v", tc.name, err)
+		}
+		if e, g := tc.n, len(got["a"]); e != g {
+			t.Errorf("[%s] expected %d scores but got %d", tc.name, e, g)
+		}
+
+		// new records land after the truncated log and replay cleanly
+		if err := fs.Store(context.Background(), &score.TestScore{TName: "a", TValue: 3}); err != nil {
+			t.Fatalf("[%s] failed to store: %v", tc.name, err)
+		}
+		if err := fs.Close(); err != nil {
+			t.Fatalf("[%s] failed to close: %v", tc.name, err)
+		}
+
+		fs, err = OpenFileStore(path)
+		if err != nil {
+			t.Fatalf("[%s] failed to reopen: %v", tc.name, err)
+		}
+		got, err = fs.Retrieve(context.Background(), factory, "test", Query{})
+		if err != nil {
+			t.Fatalf("[%s] failed to retrieve: %v", tc.name, err)
+		}
+		if e, g := tc.n+1, len(got["a"]); e != g {
+			t.Errorf("[%s] expected %d scores after reopening but got %d", tc.name, e, g)
+		}
+		fs.Close()
+	}
+}
+
+func TestFileStoreClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.log")
+	fs, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Store(context.Background(), &score.TestScore{TName: "a"}); err != ErrClosed {
+		t.Errorf("expected error '%v' but got '%v'", ErrClosed, err)
+	}
+	if err := fs.Close(); err != ErrClosed {
+		t.Errorf("expected error '%v' but got '%v'", ErrClosed, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the log to remain: %v", err)
+	}
+}
+
+func TestFileStoreCloseConcurrent(t *testing.T) {
+	fs, err := OpenFileStore(filepath.Join(t.TempDir(), "scores.log"), WithSyncInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- fs.Close() }()
+	}
+
+	// one Close closes the log, the others find it closed
+	var closed int
+	for i := 0; i < cap(errs); i++ {
+		switch err := <-errs; err {
+		case nil:
+		case ErrClosed:
+			closed++
+		default:
+			t.Errorf("expected no error or '%v' but got '%v'", ErrClosed, err)
+		}
+	}
+	if e, g := cap(errs)-1, closed; e != g {
+		t.Errorf("expected %d closes to find the log closed but got %d", e, g)
+	}
+}
+
+// intScore is a score the log can't keep, with an int value.
+type intScore struct {
+	score.TestScore
+}
+
+func (s *intScore) Type() string {
+	return "batch"
+}
+
+func (s *intScore) Value() interface{} {
+	return 1
+}
@@ -1,7 +1,10 @@
 package store
 
 import (
+	"context"
 	"errors"
+	"sort"
+	"sync"
 
 	"github.com/bdharris08/scorekeeper/score"
 )
@@ -9,34 +12,144 @@ import (
 // MemoryStore keeps scores in memory.
 // It will be used if no other store is provided.
 // Organize scores in labeled lists.
+// It is safe for concurrent use, so a ScoreKeeper can share it with other readers and writers.
+// A ScoreKeeper sharing it doesn't count the scores other writers store in the stats it keeps running until it is started again.
+// The zero MemoryStore is empty and ready to use.
 type MemoryStore struct {
-	S map[string]map[string][]score.Score
+	// mu guards s. Retrieve and the accessors share it, Store and StoreBatch take it alone.
+	mu sync.RWMutex
+	// s holds scores by scoreType, then name.
+	s map[string]map[string][]score.Score
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{s: map[string]map[string][]score.Score{}}
 }
 
 // Store a Score in memory.
-func (ms *MemoryStore) Store(s score.Score) error {
-	if ms.S == nil {
-		ms.S = map[string]map[string][]score.Score{}
+func (ms *MemoryStore) Store(ctx context.Context, s score.Score) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.add(s)
+	return nil
+}
+
+// StoreBatch stores many Scores in memory under one lock.
+func (ms *MemoryStore) StoreBatch(ctx context.Context, ss []score.Score) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, s := range ss {
+		ms.add(s)
+	}
+	return nil
+}
+
+// add a Score to s. The caller must hold mu.
+func (ms *MemoryStore) add(s score.Score) {
+	if ms.s == nil {
+		ms.s = map[string]map[string][]score.Score{}
 	}
 
 	t := s.Type()
 	n := s.Name()
 
-	if ms.S[t] == nil {
-		ms.S[t] = map[string][]score.Score{}
+	if ms.s[t] == nil {
+		ms.s[t] = map[string][]score.Score{}
 	}
 
-	ms.S[t][n] = append(ms.S[t][n], s)
-	return nil
+	ms.s[t][n] = append(ms.s[t][n], s)
 }
 
 var ErrNoScores = errors.New("no scores found")
 
-// Retrieve Scores from memory by name.
-func (ms *MemoryStore) Retrieve(f score.ScoreFactory, scoreType string) (map[string][]score.Score, error) {
-	if ms.S == nil {
+// Retrieve Scores matching the query from memory by name.
+// The map and slices returned are copies, so changing them doesn't change the store,
+// but the scores in them are the ones the store holds, and must not be modified.
+func (ms *MemoryStore) Retrieve(ctx context.Context, f score.ScoreFactory, scoreType string, q Query) (map[string][]score.Score, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if ms.s == nil {
 		return nil, ErrNoScores
 	}
 
-	return ms.S[scoreType], nil
+	ret := make(map[string][]score.Score, len(ms.s[scoreType]))
+	for name, scores := range ms.s[scoreType] {
+		if q.All() {
+			ret[name] = append([]score.Score(nil), scores...)
+			continue
+		}
+
+		for _, s := range scores {
+			if q.Matches(s) {
+				ret[name] = append(ret[name], s)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// ScoreTypes returns the scoreTypes with scores in the store, sorted.
+func (ms *MemoryStore) ScoreTypes() []string {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	types := make([]string, 0, len(ms.s))
+	for t := range ms.s {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// Names returns the names of scoreType's scores in the store, like a Trial's actions, sorted.
+func (ms *MemoryStore) Names(scoreType string) []string {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	names := make([]string, 0, len(ms.s[scoreType]))
+	for n := range ms.s[scoreType] {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Scores returns a copy of the scores of scoreType with the name, in the order they were stored.
+// The scores are the ones the store holds, and must not be modified, like with Set.
+func (ms *MemoryStore) Scores(scoreType, name string) []score.Score {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return append([]score.Score(nil), ms.s[scoreType][name]...)
+}
+
+// Len returns the number of scores of scoreType in the store.
+func (ms *MemoryStore) Len(scoreType string) int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	n := 0
+	for _, scores := range ms.s[scoreType] {
+		n += len(scores)
+	}
+	return n
 }
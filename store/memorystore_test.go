@@ -1,6 +1,9 @@
 package store
 
 import (
+	"context"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/bdharris08/scorekeeper/score"
@@ -8,20 +11,18 @@ import (
 
 func TestMemoryStoreSimple(t *testing.T) {
 	scoreType := "test"
-	ms := MemoryStore{
-		S: map[string]map[string][]score.Score{},
-	}
+	ms := NewMemoryStore()
 
 	s := score.TestScore{
 		TName:  scoreType,
 		TValue: 1,
 	}
 
-	if err := ms.Store(&s); err != nil {
+	if err := ms.Store(context.Background(), &s); err != nil {
 		t.Error(err)
 	}
 
-	scores, err := ms.Retrieve(nil, scoreType)
+	scores, err := ms.Retrieve(context.Background(), nil, scoreType, Query{})
 	if err != nil {
 		t.Error(err)
 	}
@@ -29,3 +30,100 @@ func TestMemoryStoreSimple(t *testing.T) {
 		t.Errorf("Expected %s but got %s", expected, got)
 	}
 }
+
+func TestMemoryStoreBehaviour(t *testing.T) {
+	testScoreStore(t, &MemoryStore{})
+	testBatchStore(t, &MemoryStore{}, nil)
+}
+
+func TestMemoryStoreAccessors(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+
+	scores := []score.Score{
+		&score.Trial{Action: "jump", Time: 100},
+		&score.Trial{Action: "hop", Time: 50},
+		&score.Trial{Action: "jump", Time: 200},
+		&score.TestScore{TName: "a", TValue: 1},
+	}
+	if err := ms.StoreBatch(ctx, scores); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, got := []string{"test", "trial"}, ms.ScoreTypes(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected scoreTypes %v but got %v", expected, got)
+	}
+	if expected, got := []string{"hop", "jump"}, ms.Names("trial"); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected names %v but got %v", expected, got)
+	}
+	if expected, got := 3, ms.Len("trial"); expected != got {
+		t.Errorf("Expected %d scores but got %d", expected, got)
+	}
+	if expected, got := 0, ms.Len("race"); expected != got {
+		t.Errorf("Expected %d scores but got %d", expected, got)
+	}
+
+	jumps := ms.Scores("trial", "jump")
+	if expected, got := []score.Score{scores[0], scores[2]}, jumps; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected scores %v but got %v", expected, got)
+	}
+
+	// what the store returns can be changed without changing the store
+	jumps[0] = scores[1]
+	retrieved, err := ms.Retrieve(ctx, nil, "trial", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved["jump"][1] = scores[1]
+	retrieved["jump"] = append(retrieved["jump"], scores[1])
+	delete(retrieved, "hop")
+
+	again, err := ms.Retrieve(ctx, nil, "trial", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := []score.Score{scores[0], scores[2]}, again["jump"]; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected scores %v but got %v", expected, got)
+	}
+	if expected, got := 1, len(again["hop"]); expected != got {
+		t.Errorf("Expected %d hop but got %d", expected, got)
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+
+	const writers, writes = 8, 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				if err := ms.Store(ctx, &score.Trial{Action: "jump", Time: float64(j)}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				scores, err := ms.Retrieve(ctx, nil, "trial", Query{})
+				if err != nil && err != ErrNoScores {
+					t.Error(err)
+				}
+				for _, s := range scores["jump"] {
+					_ = s.Value()
+				}
+				_ = ms.Len("trial")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if expected, got := writers*writes, ms.Len("trial"); expected != got {
+		t.Errorf("Expected %d scores but got %d", expected, got)
+	}
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// migration changes the schema of one scoreType's table.
+// Migrations are applied in order, once per table, and recorded in schema_migrations.
+type migration struct {
+	version int
+	// statement returns the DDL for the migration, given the quoted table name.
+	statement func(d Dialect, table string) string
+}
+
+// migrations for every scoreType table. Only ever append to this list.
+var migrations = []migration{
+	{
+		version: 1,
+		statement: func(d Dialect, table string) string {
+			if d == SQLite {
+				return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	value REAL NOT NULL
+)`, table)
+			}
+
+			// why bigint? https://www.cybertec-postgresql.com/en/uuid-serial-or-identity-columns-for-postgresql-auto-generated-primary-keys/
+			return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	name text NOT NULL,
+	value numeric NOT NULL
+)`, table)
+		},
+	},
+	{
+		version: 2,
+		statement: func(d Dialect, table string) string {
+			return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN cohort text NOT NULL DEFAULT ''`, table)
+		},
+	},
+	{
+		version: 3,
+		statement: func(d Dialect, table string) string {
+			return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN recorded_at %s`, table, d.timestamp())
+		},
+	},
+	{
+		version: 4,
+		statement: func(d Dialect, table string) string {
+			return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN happened_at %s`, table, d.timestamp())
+		},
+	},
+	{
+		version: 5,
+		statement: func(d Dialect, table string) string {
+			return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN player text NOT NULL DEFAULT ''`, table)
+		},
+	},
+	{
+		version: 6,
+		statement: func(d Dialect, table string) string {
+			if d == SQLite {
+				return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN outlier INTEGER NOT NULL DEFAULT 0`, table)
+			}
+			return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN outlier boolean NOT NULL DEFAULT false`, table)
+		},
+	},
+}
+
+var ErrNoScoreFactory = errors.New("scoreTypes must be registered with WithScoreFactory")
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	score_type text NOT NULL,
+	version integer NOT NULL,
+	PRIMARY KEY (score_type, version)
+)`
+
+// Migrate creates or updates the table of every scoreType registered with WithScoreFactory.
+// It is safe to run more than once; applied migrations are skipped.
+func (st *SQLStore) Migrate(ctx context.Context) error {
+	if st.f == nil {
+		return ErrNoScoreFactory
+	}
+
+	for scoreType := range st.f {
+		t, err := table(st.f, scoreType)
+		if err != nil {
+			return err
+		}
+
+		if err := st.migrateOnce(ctx, scoreType, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateOnce migrates scoreType's table, unless this store already has.
+func (st *SQLStore) migrateOnce(ctx context.Context, scoreType, table string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.tables[scoreType] {
+		return nil
+	}
+
+	if err := st.migrate(ctx, scoreType, table); err != nil {
+		return err
+	}
+
+	st.tables[scoreType] = true
+	return nil
+}
+
+// upgradeOnce migrates scoreType's table if it already exists, unless this store already has,
+// so a table made before a migration was added keeps working without WithAutoMigrate.
+// A missing table is left for Migrate to create.
+func (st *SQLStore) upgradeOnce(ctx context.Context, scoreType, table string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.tables[scoreType] {
+		return nil
+	}
+
+	var exists bool
+	// to_regclass takes the quoted name, and is NULL if there is no such table
+	if err := st.DB.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check for table %s: %w", table, err)
+	}
+	if !exists {
+		return nil
+	}
+
+	if err := st.migrate(ctx, scoreType, table); err != nil {
+		return err
+	}
+
+	st.tables[scoreType] = true
+	return nil
+}
+
+// migrate applies the migrations scoreType's table is missing, in one transaction.
+func (st *SQLStore) migrate(ctx context.Context, scoreType, table string) error {
+	if _, err := st.DB.ExecContext(ctx, createSchemaMigrations); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	tx, err := st.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	applied, err := appliedMigrations(ctx, tx, st.dialect, scoreType)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	record := fmt.Sprintf("INSERT INTO schema_migrations(score_type, version) values(%s,%s)",
+		st.dialect.placeholder(1), st.dialect.placeholder(2))
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, m.statement(st.dialect, table)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d to %s: %w", m.version, table, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, record, scoreType, m.version); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to record migration %d to %s: %w", m.version, table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// appliedMigrations returns the versions already applied to scoreType's table.
+func appliedMigrations(ctx context.Context, tx *sql.Tx, d Dialect, scoreType string) (map[int]bool, error) {
+	query := fmt.Sprintf("SELECT version FROM schema_migrations WHERE score_type = %s", d.placeholder(1))
+	rows, err := tx.QueryContext(ctx, query, scoreType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+		applied[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	return applied, nil
+}
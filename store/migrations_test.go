@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+func TestMigratePostgres(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	factory := score.ScoreFactory{"test": score.NewTestScore}
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM schema_migrations WHERE score_type = $1")).
+		WithArgs("test").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	for _, m := range migrations {
+		mock.ExpectExec(regexp.QuoteMeta(m.statement(Postgres, `"test"`))).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations(score_type, version) values($1,$2)")).
+			WithArgs("test", m.version).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	if _, err := NewSQLStore(db, WithScoreFactory(factory), WithAutoMigrate()); err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+// expectUpToDate expects the checks made the first time an up to date Postgres table is used without WithAutoMigrate.
+func expectUpToDate(mock sqlmock.Sqlmock, scoreType string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass($1) IS NOT NULL")).
+		WithArgs(`"` + scoreType + `"`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	versions := sqlmock.NewRows([]string{"version"})
+	for _, m := range migrations {
+		versions.AddRow(m.version)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM schema_migrations WHERE score_type = $1")).
+		WithArgs(scoreType).
+		WillReturnRows(versions)
+	mock.ExpectCommit()
+}
+
+func TestUpgradePostgres(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// a table made before migrations existed is brought up to date the first time it's used
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass($1) IS NOT NULL")).
+		WithArgs(`"test"`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM schema_migrations WHERE score_type = $1")).
+		WithArgs("test").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	for _, m := range migrations {
+		mock.ExpectExec(regexp.QuoteMeta(m.statement(Postgres, `"test"`))).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations(score_type, version) values($1,$2)")).
+			WithArgs("test", m.version).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	// and isn't checked again
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "test"`)).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+
+	// a missing table isn't created
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass($1) IS NOT NULL")).
+		WithArgs(`"other"`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "other"`)).WillReturnError(errors.New(`relation "other" does not exist`))
+
+	st, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := st.Store(ctx, &score.TestScore{TName: "test", TValue: float64(1)}); err != nil {
+			t.Fatalf("failed to store: %v", err)
+		}
+	}
+	if _, err := st.Retrieve(ctx, score.ScoreFactory{"other": score.NewTestScore}, "other", Query{}); err == nil {
+		t.Errorf("expected an error retrieving from a missing table")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestMigrateNoFactory(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	st, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	if err := st.Migrate(context.Background()); err != ErrNoScoreFactory {
+		t.Errorf("expected error '%v' but got '%v'", ErrNoScoreFactory, err)
+	}
+}
+
+func TestMigrateIdempotent(t *testing.T) {
+	db := openSQLite(t)
+	ctx := context.Background()
+	factory := score.ScoreFactory{
+		"test":  score.NewTestScore,
+		"trial": score.NewTrial,
+	}
+
+	// a table made by hand before migrations existed keeps its scores
+	if _, err := db.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, value REAL NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO test(name, value) values('a', 1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		st, err := NewSQLStore(db, WithDialect(SQLite), WithScoreFactory(factory))
+		if err != nil {
+			t.Fatalf("failed to initialize sqlstore: %v", err)
+		}
+		if err := st.Migrate(ctx); err != nil {
+			t.Fatalf("failed to migrate again: %v", err)
+		}
+
+		var n int
+		if err := db.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if e, g := len(factory)*len(migrations), n; e != g {
+			t.Errorf("expected %d applied migrations but got %d", e, g)
+		}
+
+		got, err := st.Retrieve(ctx, factory, "test", Query{})
+		if err != nil {
+			t.Fatalf("failed to retrieve: %v", err)
+		}
+		if e, g := 1, len(got["a"]); e != g {
+			t.Errorf("expected %d scores but got %d", e, g)
+		}
+
+		trials, err := st.Retrieve(ctx, factory, "trial", Query{})
+		if err != nil {
+			t.Fatalf("failed to retrieve: %v", err)
+		}
+		if len(trials) != 0 {
+			t.Errorf("expected no trials but got %v", trials)
+		}
+	}
+}
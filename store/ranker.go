@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// Metric is what players are ranked by on a leaderboard.
+type Metric int
+
+const (
+	// Best ranks players by their best score.
+	Best Metric = iota
+	// Average ranks players by their average score.
+	Average
+	// Total ranks players by the sum of their scores, highest first, like total points or time played.
+	Total
+)
+
+func (m Metric) String() string {
+	switch m {
+	case Best:
+		return "best"
+	case Average:
+		return "average"
+	case Total:
+		return "total"
+	default:
+		return fmt.Sprintf("Metric(%d)", int(m))
+	}
+}
+
+// Standing is a player's place on a leaderboard.
+type Standing struct {
+	Player string
+	// Rank from 1. Players with the same value share a rank, and the ranks after them are skipped.
+	Rank int
+	// Value the player is ranked by.
+	Value float64
+	// Count of the player's scores.
+	Count int
+}
+
+// RankQuery asks for a leaderboard of the players of one action.
+type RankQuery struct {
+	Action string
+	Metric Metric
+	// Higher ranks higher Best and Average values first, for scores where higher is better.
+	// See score.HigherIsBetter.
+	Higher bool
+	// Limit to the top standings, or every standing if it isn't positive.
+	Limit int
+	// Player, if set, asks only for that player's standing.
+	Player string
+}
+
+// Descending reports whether the query ranks higher values first.
+func (q RankQuery) Descending() bool {
+	return q.Metric == Total || q.Higher
+}
+
+// Ranker is a ScoreStore that ranks players itself, like SQLStore with window functions.
+// Only scores with a player are ranked. Ties are listed by player.
+type Ranker interface {
+	ScoreStore
+	Rank(ctx context.Context, f score.ScoreFactory, scoreType string, q RankQuery) ([]Standing, error)
+}
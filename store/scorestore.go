@@ -1,16 +1,92 @@
 package store
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/bdharris08/scorekeeper/score"
 )
 
 // ScoreStore stores scores for ScoreKeeper.
 // It could be in memory or backed by a database.
+// Implementations should give up and return an error when ctx is done.
+// Retrieve returns each action's scores in the order they were stored.
 type ScoreStore interface {
-	Store(s score.Score) error
-	Retrieve(f score.ScoreFactory, scoreType string) (map[string][]score.Score, error)
+	Store(ctx context.Context, s score.Score) error
+	Retrieve(ctx context.Context, f score.ScoreFactory, scoreType string, q Query) (map[string][]score.Score, error)
+}
+
+// BatchStore is a ScoreStore that can store many scores at once, like one multi-row insert.
+// StoreBatch keeps either every score or, if it returns an error, none of them.
+// ScoreKeeper uses it for AddActions if the store implements it.
+type BatchStore interface {
+	ScoreStore
+	StoreBatch(ctx context.Context, ss []score.Score) error
+}
+
+// Query narrows the scores Retrieve returns.
+// The zero Query matches every score.
+type Query struct {
+	// Cohorts to include. Empty means every cohort.
+	Cohorts []string
+	// Since includes only scores for actions at or after it, unless it is zero.
+	Since time.Time
+	// Until includes only scores for actions before it, unless it is zero.
+	Until time.Time
+}
+
+// All reports whether the query matches every score.
+func (q Query) All() bool {
+	return len(q.Cohorts) == 0 && q.Since.IsZero() && q.Until.IsZero()
+}
+
+// Matches reports whether the query includes score s.
+func (q Query) Matches(s score.Score) bool {
+	return q.matches(score.CohortOf(s), score.WhenOf(s))
+}
+
+// matches reports whether the query includes a score with the given cohort and time.
+// Scores with no time are only included when the query has no time bounds.
+func (q Query) matches(cohort string, when time.Time) bool {
+	if !q.Since.IsZero() && (when.IsZero() || when.Before(q.Since)) {
+		return false
+	}
+	if !q.Until.IsZero() && (when.IsZero() || !when.Before(q.Until)) {
+		return false
+	}
+
+	if len(q.Cohorts) == 0 {
+		return true
+	}
+
+	for _, c := range q.Cohorts {
+		if c == cohort {
+			return true
+		}
+	}
+
+	return false
 }
 
 var ErrNoStore = errors.New("scoreStore uninitialized")
+
+// newScore creates a Score of scoreType from what a store kept of it.
+// The Meta is only kept by scores that are Annotated.
+func newScore(f score.ScoreFactory, scoreType, name string, value float64, meta score.Meta) (score.Score, error) {
+	s, err := score.Create(f, scoreType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create score: %v", err)
+	}
+
+	if err := s.Set(name, value); err != nil {
+		return nil, fmt.Errorf("failed to set score: %w", err)
+	}
+
+	if m := score.MetadataOf(s); m != nil {
+		*m = meta
+	}
+
+	return s, nil
+}
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+)
+
+// testScoreStore checks the behaviour every ScoreStore should share.
+func testScoreStore(t *testing.T, st ScoreStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	factory := score.ScoreFactory{
+		"test":   score.NewTestScore,
+		"trial":  score.NewTrial,
+		"unused": score.NewTestScore,
+	}
+
+	scores := []score.Score{
+		&score.TestScore{TName: "a", TValue: 1},
+		&score.TestScore{TName: "a", TValue: 2},
+		&score.TestScore{TName: "b", TValue: -3.5},
+		&score.Trial{Action: "jump", Time: 100},
+	}
+	for _, s := range scores {
+		if err := st.Store(ctx, s); err != nil {
+			t.Fatalf("failed to store %v: %v", s, err)
+		}
+	}
+
+	got, err := st.Retrieve(ctx, factory, "test", Query{})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+
+	expected := map[string][]float64{
+		"a": {1, 2},
+		"b": {-3.5},
+	}
+	if e, g := len(expected), len(got); e != g {
+		t.Errorf("expected %d names but got %d", e, g)
+	}
+	for name, values := range expected {
+		if e, g := len(values), len(got[name]); e != g {
+			t.Errorf("expected %d scores for %s but got %d", e, name, g)
+			continue
+		}
+		for i, v := range values {
+			s := got[name][i]
+			if e, g := name, s.Name(); e != g {
+				t.Errorf("expected name %s but got %s", e, g)
+			}
+			if e, g := v, s.Value(); e != g {
+				t.Errorf("expected %s[%d] to be %v but got %v", name, i, e, g)
+			}
+		}
+	}
+
+	trials, err := st.Retrieve(ctx, factory, "trial", Query{})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	if e, g := 1, len(trials["jump"]); e != g {
+		t.Errorf("expected %d trial but got %d", e, g)
+	}
+
+	none, err := st.Retrieve(ctx, factory, "unused", Query{})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	if len(none) != 0 {
+		t.Errorf("expected no scores for an unused scoreType but got %v", none)
+	}
+
+	cohorts := []score.Score{
+		&score.Trial{Action: "hop", Time: 1, Meta: score.Meta{Cohort: "run-1", Player: "ann"}},
+		&score.Trial{Action: "hop", Time: 2, Meta: score.Meta{Cohort: "run-2", Outlier: true}},
+		&score.Trial{Action: "hop", Time: 3, Meta: score.Meta{Cohort: "run-2"}},
+	}
+	for _, s := range cohorts {
+		if err := st.Store(ctx, s); err != nil {
+			t.Fatalf("failed to store %v: %v", s, err)
+		}
+	}
+
+	type cohortCase struct {
+		cohorts []string
+		hops    int
+		jumps   int
+	}
+	cohortCases := []cohortCase{
+		{cohorts: nil, hops: 3, jumps: 1},
+		{cohorts: []string{"run-1"}, hops: 1},
+		{cohorts: []string{"run-2"}, hops: 2},
+		{cohorts: []string{"run-1", "run-2"}, hops: 3},
+		{cohorts: []string{""}, jumps: 1},
+		{cohorts: []string{"run-3"}},
+	}
+	for _, cc := range cohortCases {
+		got, err := st.Retrieve(ctx, factory, "trial", Query{Cohorts: cc.cohorts})
+		if err != nil {
+			t.Fatalf("failed to retrieve: %v", err)
+		}
+		if e, g := cc.hops, len(got["hop"]); e != g {
+			t.Errorf("%v: expected %d hops but got %d", cc.cohorts, e, g)
+		}
+		if e, g := cc.jumps, len(got["jump"]); e != g {
+			t.Errorf("%v: expected %d jumps but got %d", cc.cohorts, e, g)
+		}
+		for _, s := range got["hop"] {
+			if q := (Query{Cohorts: cc.cohorts}); !q.Matches(s) {
+				t.Errorf("%v: got a score from cohort %q", cc.cohorts, score.CohortOf(s))
+			}
+		}
+	}
+
+	// players round-trip through the store
+	run1, err := st.Retrieve(ctx, factory, "trial", Query{Cohorts: []string{"run-1"}})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	if len(run1["hop"]) != 1 {
+		t.Fatalf("expected 1 hop in run-1 but got %d", len(run1["hop"]))
+	}
+	if e, g := "ann", score.PlayerOf(run1["hop"][0]); e != g {
+		t.Errorf("expected player %s but got %s", e, g)
+	}
+
+	// outliers round-trip through the store
+	run2, err := st.Retrieve(ctx, factory, "trial", Query{Cohorts: []string{"run-2"}})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	outliers := 0
+	for _, s := range run2["hop"] {
+		if score.IsOutlier(s) {
+			outliers++
+		}
+	}
+	if e, g := 1, outliers; e != g {
+		t.Errorf("expected %d outlier but got %d", e, g)
+	}
+
+	// times round-trip through the store, and bound what Retrieve returns
+	base := time.Date(2022, 3, 4, 5, 6, 7, 500000000, time.UTC)
+	timed := []score.Score{
+		&score.Trial{Action: "sit", Time: 1, Meta: score.Meta{Recorded: base}},
+		&score.Trial{Action: "sit", Time: 2, Meta: score.Meta{Recorded: base.Add(time.Hour)}},
+		&score.Trial{Action: "sit", Time: 3, Meta: score.Meta{At: base.Add(2 * time.Hour), Recorded: base}},
+		&score.Trial{Action: "sit", Time: 4, Meta: score.Meta{At: base.Add(-time.Minute).In(time.FixedZone("east", 3600))}},
+	}
+	for _, s := range timed {
+		if err := st.Store(ctx, s); err != nil {
+			t.Fatalf("failed to store %v: %v", s, err)
+		}
+	}
+
+	type timeCase struct {
+		name         string
+		since, until time.Time
+		times        []float64
+	}
+	timeCases := []timeCase{
+		{name: "all", times: []float64{1, 2, 3, 4}},
+		{name: "since", since: base, times: []float64{1, 2, 3}},
+		{name: "until", until: base.Add(time.Hour), times: []float64{1, 4}},
+		{name: "between", since: base.Add(time.Minute), until: base.Add(3 * time.Hour), times: []float64{2, 3}},
+		{name: "at wins", since: base.Add(90 * time.Minute), times: []float64{3}},
+		{name: "empty", since: base.Add(time.Hour), until: base.Add(time.Hour)},
+	}
+	for _, tc := range timeCases {
+		got, err := st.Retrieve(ctx, factory, "trial", Query{Since: tc.since, Until: tc.until})
+		if err != nil {
+			t.Fatalf("[%s] failed to retrieve: %v", tc.name, err)
+		}
+
+		gotTimes := map[float64]bool{}
+		for _, s := range got["sit"] {
+			gotTimes[s.Value().(float64)] = true
+		}
+		if e, g := len(tc.times), len(gotTimes); e != g {
+			t.Errorf("[%s] expected %d scores but got %d", tc.name, e, g)
+		}
+		for _, v := range tc.times {
+			if !gotTimes[v] {
+				t.Errorf("[%s] expected score %v", tc.name, v)
+			}
+		}
+	}
+
+	sits, err := st.Retrieve(ctx, factory, "trial", Query{})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	for _, s := range sits["sit"] {
+		if e, g := timed[int(s.Value().(float64))-1], s; !score.WhenOf(e).Equal(score.WhenOf(g)) {
+			t.Errorf("expected score %v to have happened at %v but got %v", s.Value(), score.WhenOf(e), score.WhenOf(g))
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := st.Store(canceled, &score.TestScore{TName: "a", TValue: 3}); err == nil {
+		t.Errorf("expected Store to fail with a canceled context")
+	}
+	if _, err := st.Retrieve(canceled, factory, "test", Query{}); err == nil {
+		t.Errorf("expected Retrieve to fail with a canceled context")
+	}
+}
+
+// testBatchStore checks the behaviour every BatchStore should share.
+// If bad is not nil, storing it must fail, and the batch with it must keep nothing.
+func testBatchStore(t *testing.T, st BatchStore, bad score.Score) {
+	t.Helper()
+
+	ctx := context.Background()
+	factory := score.ScoreFactory{
+		"batch": score.NewTestScore,
+		"trial": score.NewTrial,
+	}
+
+	// more scores than fit in one insert, with other scoreTypes between them
+	var batch []score.Score
+	for i := 0; i < 250; i++ {
+		batch = append(batch, &typedScore{TestScore: score.TestScore{TName: "a", TValue: float64(i)}, t: "batch"})
+		if i%50 == 0 {
+			batch = append(batch, &score.Trial{Action: "batched", Time: float64(i)})
+		}
+	}
+	if err := st.StoreBatch(ctx, batch); err != nil {
+		t.Fatalf("failed to store batch: %v", err)
+	}
+
+	got, err := st.Retrieve(ctx, factory, "batch", Query{})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	if e, g := 250, len(got["a"]); e != g {
+		t.Fatalf("expected %d batched scores but got %d", e, g)
+	}
+	for i, s := range got["a"] {
+		if e, g := float64(i), s.Value(); e != g {
+			t.Errorf("expected batched score %d to be %v but got %v", i, e, g)
+		}
+	}
+
+	trials, err := st.Retrieve(ctx, factory, "trial", Query{Cohorts: []string{""}})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	if e, g := 5, len(trials["batched"]); e != g {
+		t.Errorf("expected %d batched trials but got %d", e, g)
+	}
+
+	if bad == nil {
+		return
+	}
+
+	failing := []score.Score{
+		&typedScore{TestScore: score.TestScore{TName: "b", TValue: 1}, t: "batch"},
+		bad,
+	}
+	if err := st.StoreBatch(ctx, failing); err == nil {
+		t.Fatalf("expected a batch with %v to fail", bad)
+	}
+
+	got, err = st.Retrieve(ctx, factory, "batch", Query{})
+	if err != nil {
+		t.Fatalf("failed to retrieve: %v", err)
+	}
+	if len(got["b"]) != 0 {
+		t.Errorf("expected a failed batch to keep nothing but got %v", got["b"])
+	}
+}
@@ -1,55 +1,232 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bdharris08/scorekeeper/score"
 )
 
-/* Example Schema (postgres):
-// why bigint? https://www.cybertec-postgresql.com/en/uuid-serial-or-identity-columns-for-postgresql-auto-generated-primary-keys/
-TODO CREATE TABLE cohort
+/* Schema (postgres), created by Migrate:
 CREATE TABLE <scoreType> (
 	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
 	name text NOT NULL,
-	value numeric NOT NULL
+	value numeric NOT NULL,
+	cohort text NOT NULL DEFAULT '',
+	player text NOT NULL DEFAULT '',
+	-- flagged by ScoreKeeper as an outlier, kept but left out of stats
+	outlier boolean NOT NULL DEFAULT false,
+	-- when ScoreKeeper took the score in, and when the client says the action happened
+	recorded_at timestamptz,
+	happened_at timestamptz
 );
+CREATE TABLE schema_migrations (
+	score_type text NOT NULL,
+	version integer NOT NULL,
+	PRIMARY KEY (score_type, version)
+);
+See migrations.go for how each table got there.
 */
 
-// SQLStore keeps scores in a postgres database.
-// It uses the `database/sql` interfaces to remain driver agnostic.
-// It must be a postgres driver due to syntax differences.
+// Dialect is the flavour of SQL spoken by the database behind a SQLStore.
+type Dialect int
+
+const (
+	// Postgres is the default Dialect.
+	// Its tables are only created with WithAutoMigrate or Migrate.
+	Postgres Dialect = iota
+	// SQLite needs no database server, which suits single-node deployments.
+	// Its tables are always created and migrated as they are needed.
+	SQLite
+)
+
+// placeholder returns the bind parameter for the n-th (1-based) argument of a query.
+func (d Dialect) placeholder(n int) string {
+	if d == SQLite {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+// identifier is the grammar for scoreTypes used as table names.
+// Lowercase only, so quoted names match the names postgres folds unquoted ones to.
+var identifier = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+var ErrInvalidIdentifier = errors.New("invalid identifier")
+
+// quoteIdentifier quotes a valid identifier for use in a query.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// timestamp is the column type for times.
+func (d Dialect) timestamp() string {
+	if d == SQLite {
+		return "TIMESTAMP"
+	}
+	return "timestamptz"
+}
+
+// nullTime is a time argument for a query, NULL if it is zero.
+// Times are kept in UTC so SQLite, which compares them as text, orders them correctly.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// SQLStore keeps scores in a SQL database.
+// It uses the `database/sql` interfaces to remain driver agnostic,
+// but the driver must match the store's Dialect due to syntax differences.
 type SQLStore struct {
 	DB *sql.DB
+
+	dialect Dialect
+	// f, if set, limits scoreTypes to those registered
+	f score.ScoreFactory
+	// autoMigrate creates and migrates tables as they are needed
+	autoMigrate bool
+	// tables known to be migrated
+	mu     sync.Mutex
+	tables map[string]bool
+}
+
+// SQLOption configures a SQLStore in NewSQLStore.
+type SQLOption func(st *SQLStore)
+
+// WithDialect sets the SQL dialect of the store. The default is Postgres.
+func WithDialect(d Dialect) SQLOption {
+	return func(st *SQLStore) {
+		st.dialect = d
+	}
+}
+
+// WithScoreFactory only lets the store keep the scoreTypes registered in f.
+// Retrieve always checks the factory it is given.
+func WithScoreFactory(f score.ScoreFactory) SQLOption {
+	return func(st *SQLStore) {
+		st.f = f
+	}
+}
+
+// WithAutoMigrate creates and migrates the table of every scoreType registered with WithScoreFactory
+// when the store is created, and of any other scoreType the first time it's used.
+// Without it, tables that already exist are still migrated the first time they're used.
+func WithAutoMigrate() SQLOption {
+	return func(st *SQLStore) {
+		st.autoMigrate = true
+	}
 }
 
 // NewSQLStore returns a new *SQLStore
-// Specify scoreTable and trialTable or use "" for defaults
-func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+func NewSQLStore(db *sql.DB, opts ...SQLOption) (*SQLStore, error) {
 	if db == nil {
 		return nil, ErrDBUninitialized
 	}
 
-	return &SQLStore{DB: db}, nil
+	st := &SQLStore{
+		DB:     db,
+		tables: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(st)
+	}
+
+	if st.dialect == SQLite {
+		st.autoMigrate = true
+	}
+
+	if st.autoMigrate && st.f != nil {
+		if err := st.Migrate(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	return st, nil
+}
+
+// table validates scoreType against the factory, if there is one, and the identifier grammar.
+// It returns the quoted table name, safe to use in a query.
+func table(f score.ScoreFactory, scoreType string) (string, error) {
+	if f != nil {
+		if _, ok := f[scoreType]; !ok {
+			return "", fmt.Errorf("%w: unregistered scoreType %q", ErrInvalidIdentifier, scoreType)
+		}
+	}
+
+	if !identifier.MatchString(scoreType) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, scoreType)
+	}
+
+	return quoteIdentifier(scoreType), nil
+}
+
+// ensureTable migrates the table for scoreType the first time it's used.
+// Without WithAutoMigrate only a table that already exists is migrated; a missing one isn't created.
+func (st *SQLStore) ensureTable(ctx context.Context, scoreType, table string) error {
+	if !st.autoMigrate {
+		return st.upgradeOnce(ctx, scoreType, table)
+	}
+
+	return st.migrateOnce(ctx, scoreType, table)
 }
 
 var ErrDBUninitialized = errors.New("db not initialized")
 var ErrTrialTable = errors.New("trial table name not specified")
 
 // Store score `s` to the database
-func (st *SQLStore) Store(s score.Score) error {
-	tx, err := st.DB.Begin()
+func (st *SQLStore) Store(ctx context.Context, s score.Score) error {
+	return st.StoreBatch(ctx, []score.Score{s})
+}
+
+// batchRows is the most rows inserted by one statement,
+// keeping the bind parameters well under every dialect's limit.
+const batchRows = 100
+
+// StoreBatch stores many scores to the database in one transaction, with multi-row inserts.
+// The scores may be of different scoreTypes.
+func (st *SQLStore) StoreBatch(ctx context.Context, ss []score.Score) error {
+	if len(ss) == 0 {
+		return nil
+	}
+
+	// validate every table before anything reaches the database
+	tables := make([]string, len(ss))
+	for i, s := range ss {
+		t, err := table(st.f, s.Type())
+		if err != nil {
+			return err
+		}
+		if err := st.ensureTable(ctx, s.Type(), t); err != nil {
+			return err
+		}
+		tables[i] = t
+	}
+
+	tx, err := st.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s(name, value) values($1,$2)", s.Type())
-	_, err = st.DB.Exec(query, s.Name(), s.Value())
-	if err != nil {
-		_ = tx.Rollback()
-		return fmt.Errorf("failed to insert score: %w", err)
+	// insert runs of scores for the same table together
+	for start := 0; start < len(ss); {
+		end := start + 1
+		for end < len(ss) && end-start < batchRows && tables[end] == tables[start] {
+			end++
+		}
+
+		if err := st.insert(ctx, tx, tables[start], ss[start:end]); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		start = end
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -59,10 +236,47 @@ func (st *SQLStore) Store(s score.Score) error {
 	return nil
 }
 
-// Retrieve Scores from the database by name
+// insert scores into table with one statement.
+func (st *SQLStore) insert(ctx context.Context, tx *sql.Tx, table string, ss []score.Score) error {
+	const columns = 7
+
+	rows := make([]string, 0, len(ss))
+	args := make([]interface{}, 0, len(ss)*columns)
+	for _, s := range ss {
+		var meta score.Meta
+		if m := score.MetadataOf(s); m != nil {
+			meta = *m
+		}
+
+		placeholders := make([]string, columns)
+		for i := range placeholders {
+			placeholders[i] = st.dialect.placeholder(len(args) + i + 1)
+		}
+		rows = append(rows, "("+strings.Join(placeholders, ",")+")")
+		args = append(args, s.Name(), s.Value(), meta.Cohort, meta.Player, meta.Outlier, nullTime(meta.Recorded), nullTime(meta.At))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s(name, value, cohort, player, outlier, recorded_at, happened_at) values%s",
+		table, strings.Join(rows, ","))
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert score: %w", err)
+	}
+
+	return nil
+}
+
+// Retrieve Scores matching the query from the database by name
 // Use `database/sql` pattern rather than talking directly to driver.
 // This should allow for swapping out drivers.
-func (st *SQLStore) Retrieve(f score.ScoreFactory, scoreType string) (map[string][]score.Score, error) {
+func (st *SQLStore) Retrieve(ctx context.Context, f score.ScoreFactory, scoreType string, q Query) (map[string][]score.Score, error) {
+	t, err := table(f, scoreType)
+	if err != nil {
+		return nil, err
+	}
+	if err := st.ensureTable(ctx, scoreType, t); err != nil {
+		return nil, err
+	}
+
 	ret := map[string][]score.Score{}
 
 	/* typical database/sql pattern:
@@ -75,8 +289,8 @@ func (st *SQLStore) Retrieve(f score.ScoreFactory, scoreType string) (map[string
 	- after looping, check for errors with rows.Err()
 	*/
 
-	query := fmt.Sprintf("SELECT name, value FROM %s", scoreType)
-	rows, err := st.DB.Query(query)
+	query, args := st.selectScores(t, q)
+	rows, err := st.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query scores: %w", err)
 	}
@@ -84,20 +298,27 @@ func (st *SQLStore) Retrieve(f score.ScoreFactory, scoreType string) (map[string
 
 	for rows.Next() {
 		var (
-			name  string
-			value float64
+			name               string
+			value              float64
+			meta               score.Meta
+			recorded, happened sql.NullTime
 		)
 
-		if err := rows.Scan(&name, &value); err != nil {
+		if err := rows.Scan(&name, &value, &meta.Cohort, &meta.Player, &meta.Outlier, &recorded, &happened); err != nil {
 			return nil, fmt.Errorf("error scanning: %w", err)
 		}
+		if recorded.Valid {
+			meta.Recorded = recorded.Time
+		}
+		if happened.Valid {
+			meta.At = happened.Time
+		}
 
-		s, err := score.Create(f, scoreType)
+		s, err := newScore(f, scoreType, name, value, meta)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create score: %v", err)
+			return nil, err
 		}
 
-		s.Set(name, value)
 		ret[name] = append(ret[name], s)
 	}
 
@@ -107,3 +328,108 @@ func (st *SQLStore) Retrieve(f score.ScoreFactory, scoreType string) (map[string
 
 	return ret, nil
 }
+
+// selectScores builds the query for the scores in table matching q, and its arguments.
+func (st *SQLStore) selectScores(table string, q Query) (string, []interface{}) {
+	query := fmt.Sprintf("SELECT name, value, cohort, player, outlier, recorded_at, happened_at FROM %s", table)
+
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	if len(q.Cohorts) > 0 {
+		in := make([]string, 0, len(q.Cohorts))
+		for _, c := range q.Cohorts {
+			args = append(args, c)
+			in = append(in, st.dialect.placeholder(len(args)))
+		}
+		where = append(where, fmt.Sprintf("cohort IN (%s)", strings.Join(in, ",")))
+	}
+
+	if !q.Since.IsZero() {
+		args = append(args, nullTime(q.Since))
+		where = append(where, fmt.Sprintf("COALESCE(happened_at, recorded_at) >= %s", st.dialect.placeholder(len(args))))
+	}
+	if !q.Until.IsZero() {
+		args = append(args, nullTime(q.Until))
+		where = append(where, fmt.Sprintf("COALESCE(happened_at, recorded_at) < %s", st.dialect.placeholder(len(args))))
+	}
+
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// in the order they were stored, which windows over the last N scores depend on
+	query += " ORDER BY id"
+
+	return query, args
+}
+
+// Rank the players of an action with window functions, so only the standings leave the database.
+func (st *SQLStore) Rank(ctx context.Context, f score.ScoreFactory, scoreType string, q RankQuery) ([]Standing, error) {
+	t, err := table(f, scoreType)
+	if err != nil {
+		return nil, err
+	}
+	if err := st.ensureTable(ctx, scoreType, t); err != nil {
+		return nil, err
+	}
+
+	var agg string
+	switch q.Metric {
+	case Best:
+		agg = "MIN"
+		if q.Higher {
+			agg = "MAX"
+		}
+	case Average:
+		agg = "AVG"
+	case Total:
+		agg = "SUM"
+	default:
+		return nil, fmt.Errorf("unknown metric %v", q.Metric)
+	}
+
+	dir := "ASC"
+	if q.Descending() {
+		dir = "DESC"
+	}
+
+	args := []interface{}{q.Action}
+	query := fmt.Sprintf(`SELECT player, value, n, place FROM (`+
+		`SELECT player, value, n, RANK() OVER (ORDER BY value %s) AS place FROM (`+
+		`SELECT player, %s(value) AS value, COUNT(*) AS n FROM %s WHERE name = %s AND player <> '' AND NOT outlier GROUP BY player`+
+		`) AS p) AS r`,
+		dir, agg, t, st.dialect.placeholder(len(args)))
+
+	if q.Player != "" {
+		args = append(args, q.Player)
+		query += fmt.Sprintf(" WHERE player = %s", st.dialect.placeholder(len(args)))
+	}
+	query += " ORDER BY place, player"
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		query += fmt.Sprintf(" LIMIT %s", st.dialect.placeholder(len(args)))
+	}
+
+	rows, err := st.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank players: %w", err)
+	}
+	defer rows.Close()
+
+	var standings []Standing
+	for rows.Next() {
+		var s Standing
+		if err := rows.Scan(&s.Player, &s.Value, &s.Count, &s.Rank); err != nil {
+			return nil, fmt.Errorf("error scanning: %w", err)
+		}
+		standings = append(standings, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	return standings, nil
+}
@@ -1,11 +1,18 @@
 package store
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/bdharris08/scorekeeper/score"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestStore(t *testing.T) {
@@ -17,15 +24,16 @@ func TestStore(t *testing.T) {
 
 	score := &score.TestScore{TName: "test", TValue: float64(0)}
 
+	expectUpToDate(mock, "test")
 	mock.ExpectBegin()
-	mock.ExpectExec("INSERT INTO test").WithArgs(score.Name(), score.Value()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "test"`)).WithArgs(score.Name(), score.Value(), "", "", false, nil, nil).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	st, err := NewSQLStore(db)
 	if err != nil {
 		t.Fatalf("failed to initialize sqlstore: %v", err)
 	}
-	if err := st.Store(score); err != nil {
+	if err := st.Store(context.Background(), score); err != nil {
 		t.Fatalf("failed to store test score: %v", err)
 	}
 
@@ -42,11 +50,12 @@ func TestRetrieve(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"action", "value"}).
-		AddRow("a", float64(0)).
-		AddRow("a", float64(1))
+	rows := sqlmock.NewRows([]string{"action", "value", "cohort", "player", "outlier", "recorded_at", "happened_at"}).
+		AddRow("a", float64(0), "", "", false, nil, nil).
+		AddRow("a", float64(1), "", "", false, nil, nil)
 
-	mock.ExpectQuery(fmt.Sprintf("SELECT name, value FROM %s", scoreType)).WillReturnRows(rows)
+	expectUpToDate(mock, scoreType)
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(`SELECT name, value, cohort, player, outlier, recorded_at, happened_at FROM "%s" ORDER BY id`, scoreType))).WillReturnRows(rows)
 
 	st, err := NewSQLStore(db)
 	if err != nil {
@@ -57,7 +66,7 @@ func TestRetrieve(t *testing.T) {
 		scoreType: func() score.Score { return &score.TestScore{} },
 	}
 
-	got, err := st.Retrieve(factory, scoreType)
+	got, err := st.Retrieve(context.Background(), factory, scoreType, Query{})
 	if err != nil {
 		t.Fatalf("failed to retrieve rows: %v", err)
 	}
@@ -102,3 +111,313 @@ func TestRetrieve(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
+
+func TestRetrieveQuery(t *testing.T) {
+	scoreType := "test"
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	since := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	until := since.Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"action", "value", "cohort", "player", "outlier", "recorded_at", "happened_at"}).
+		AddRow("a", float64(0), "run-1", "ann", false, since, nil).
+		AddRow("a", float64(1), "run-2", "bob", true, since, since.Add(time.Minute))
+
+	expectUpToDate(mock, scoreType)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT name, value, cohort, player, outlier, recorded_at, happened_at FROM "test" `+
+		`WHERE cohort IN ($1,$2) AND COALESCE(happened_at, recorded_at) >= $3 AND COALESCE(happened_at, recorded_at) < $4 ORDER BY id`)).
+		WithArgs("run-1", "run-2", since, until).
+		WillReturnRows(rows)
+
+	st, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	factory := score.ScoreFactory{
+		scoreType: func() score.Score { return &score.TestScore{} },
+	}
+
+	got, err := st.Retrieve(context.Background(), factory, scoreType, Query{Cohorts: []string{"run-1", "run-2"}, Since: since, Until: until})
+	if err != nil {
+		t.Fatalf("failed to retrieve rows: %v", err)
+	}
+
+	for i, c := range []string{"run-1", "run-2"} {
+		if e, g := c, score.CohortOf(got["a"][i]); e != g {
+			t.Errorf("expected cohort %s but got %s", e, g)
+		}
+	}
+	for i, p := range []string{"ann", "bob"} {
+		if e, g := p, score.PlayerOf(got["a"][i]); e != g {
+			t.Errorf("expected player %s but got %s", e, g)
+		}
+	}
+	for i, outlier := range []bool{false, true} {
+		if e, g := outlier, score.IsOutlier(got["a"][i]); e != g {
+			t.Errorf("expected outlier %v but got %v", e, g)
+		}
+	}
+	for i, when := range []time.Time{since, since.Add(time.Minute)} {
+		if e, g := when, score.WhenOf(got["a"][i]); !e.Equal(g) {
+			t.Errorf("expected score to have happened at %v but got %v", e, g)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestInvalidIdentifier(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	names := []string{
+		"test; DROP TABLE test",
+		"test\"",
+		"Test",
+		"1test",
+		"",
+		"unregistered",
+	}
+
+	factory := score.ScoreFactory{}
+	for _, name := range names {
+		factory[name] = func() score.Score { return &score.TestScore{} }
+	}
+	delete(factory, "unregistered")
+
+	st, err := NewSQLStore(db, WithScoreFactory(factory))
+	if err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	for _, name := range names {
+		if _, err := st.Retrieve(context.Background(), factory, name, Query{}); !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("[%q] Retrieve: expected error '%v' but got '%v'", name, ErrInvalidIdentifier, err)
+		}
+		if err := st.Store(context.Background(), &typedScore{t: name}); !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("[%q] Store: expected error '%v' but got '%v'", name, ErrInvalidIdentifier, err)
+		}
+	}
+
+	// nothing reached the database
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+// typedScore is a TestScore of any type.
+type typedScore struct {
+	score.TestScore
+	t string
+}
+
+func (s *typedScore) Type() string {
+	return s.t
+}
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scores.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestSQLiteBehaviour(t *testing.T) {
+	st, err := NewSQLStore(openSQLite(t), WithDialect(SQLite))
+	if err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	testScoreStore(t, st)
+	testBatchStore(t, st, &typedScore{t: "Bad-Type"})
+}
+
+func TestSQLiteDurable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.db")
+	factory := score.ScoreFactory{"test": score.NewTestScore}
+
+	for i := 0; i < 2; i++ {
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			t.Fatalf("failed to open sqlite: %v", err)
+		}
+
+		st, err := NewSQLStore(db, WithDialect(SQLite))
+		if err != nil {
+			t.Fatalf("failed to initialize sqlstore: %v", err)
+		}
+		if err := st.Store(context.Background(), &score.TestScore{TName: "a", TValue: float64(i)}); err != nil {
+			t.Fatalf("failed to store: %v", err)
+		}
+
+		got, err := st.Retrieve(context.Background(), factory, "test", Query{})
+		if err != nil {
+			t.Fatalf("failed to retrieve: %v", err)
+		}
+		if e, g := i+1, len(got["a"]); e != g {
+			t.Errorf("expected %d scores after reopening but got %d", e, g)
+		}
+
+		db.Close()
+	}
+}
+
+func TestSQLiteRank(t *testing.T) {
+	factory := score.ScoreFactory{"trial": score.NewTrial}
+	st, err := NewSQLStore(openSQLite(t), WithDialect(SQLite), WithScoreFactory(factory))
+	if err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, s := range []*score.Trial{
+		{Action: "hop", Time: 10, Meta: score.Meta{Player: "ann"}},
+		{Action: "hop", Time: 30, Meta: score.Meta{Player: "ann"}},
+		{Action: "hop", Time: 20, Meta: score.Meta{Player: "bob"}},
+		{Action: "hop", Time: 60, Meta: score.Meta{Player: "cat"}},
+		{Action: "hop", Time: 10, Meta: score.Meta{Player: "cat"}},
+		{Action: "hop", Time: 50, Meta: score.Meta{Player: "cat"}},
+		{Action: "hop", Time: 1},
+		{Action: "jump", Time: 5, Meta: score.Meta{Player: "dan"}},
+	} {
+		if err := st.Store(ctx, s); err != nil {
+			t.Fatalf("failed to store %v: %v", s, err)
+		}
+	}
+
+	type testCase struct {
+		name      string
+		q         RankQuery
+		standings []Standing
+	}
+
+	testCases := []testCase{
+		{
+			name: "best",
+			q:    RankQuery{Action: "hop", Metric: Best},
+			standings: []Standing{
+				{Player: "ann", Rank: 1, Value: 10, Count: 2},
+				{Player: "cat", Rank: 1, Value: 10, Count: 3},
+				{Player: "bob", Rank: 3, Value: 20, Count: 1},
+			},
+		},
+		{
+			name: "average",
+			q:    RankQuery{Action: "hop", Metric: Average},
+			standings: []Standing{
+				{Player: "ann", Rank: 1, Value: 20, Count: 2},
+				{Player: "bob", Rank: 1, Value: 20, Count: 1},
+				{Player: "cat", Rank: 3, Value: 40, Count: 3},
+			},
+		},
+		{
+			name: "total",
+			q:    RankQuery{Action: "hop", Metric: Total},
+			standings: []Standing{
+				{Player: "cat", Rank: 1, Value: 120, Count: 3},
+				{Player: "ann", Rank: 2, Value: 40, Count: 2},
+				{Player: "bob", Rank: 3, Value: 20, Count: 1},
+			},
+		},
+		{
+			name: "higher is better",
+			q:    RankQuery{Action: "hop", Metric: Best, Higher: true},
+			standings: []Standing{
+				{Player: "cat", Rank: 1, Value: 60, Count: 3},
+				{Player: "ann", Rank: 2, Value: 30, Count: 2},
+				{Player: "bob", Rank: 3, Value: 20, Count: 1},
+			},
+		},
+		{
+			name: "limit",
+			q:    RankQuery{Action: "hop", Metric: Best, Limit: 2},
+			standings: []Standing{
+				{Player: "ann", Rank: 1, Value: 10, Count: 2},
+				{Player: "cat", Rank: 1, Value: 10, Count: 3},
+			},
+		},
+		{
+			name: "player",
+			q:    RankQuery{Action: "hop", Metric: Best, Player: "bob"},
+			standings: []Standing{
+				{Player: "bob", Rank: 3, Value: 20, Count: 1},
+			},
+		},
+		{
+			name: "unranked player",
+			q:    RankQuery{Action: "hop", Metric: Best, Player: "dan"},
+		},
+		{
+			name: "no players",
+			q:    RankQuery{Action: "sit", Metric: Best},
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := st.Rank(ctx, factory, "trial", tc.q)
+		if err != nil {
+			t.Errorf("[%s] failed to rank: %v", tc.name, err)
+			continue
+		}
+
+		if e, g := len(tc.standings), len(got); e != g {
+			t.Errorf("[%s] expected %d standings but got %v", tc.name, e, got)
+			continue
+		}
+		for i := range tc.standings {
+			if e, g := tc.standings[i], got[i]; e != g {
+				t.Errorf("[%s] expected standing %d to be %+v but got %+v", tc.name, i, e, g)
+			}
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"player", "value", "n", "place"}).
+		AddRow("ann", float64(10), 2, 1)
+
+	expectUpToDate(mock, "test")
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT player, value, n, place FROM (`+
+		`SELECT player, value, n, RANK() OVER (ORDER BY value DESC) AS place FROM (`+
+		`SELECT player, MAX(value) AS value, COUNT(*) AS n FROM "test" WHERE name = $1 AND player <> '' AND NOT outlier GROUP BY player`+
+		`) AS p) AS r WHERE player = $2 ORDER BY place, player LIMIT $3`)).
+		WithArgs("a", "ann", 10).
+		WillReturnRows(rows)
+
+	st, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("failed to initialize sqlstore: %v", err)
+	}
+
+	got, err := st.Rank(context.Background(), nil, "test", RankQuery{Action: "a", Metric: Best, Higher: true, Limit: 10, Player: "ann"})
+	if err != nil {
+		t.Fatalf("failed to rank: %v", err)
+	}
+	if e, g := (Standing{Player: "ann", Rank: 1, Value: 10, Count: 2}), got[0]; e != g {
+		t.Errorf("expected %+v but got %+v", e, g)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package scorekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+// StatsUpdate is sent to subscribers as scores arrive.
+type StatsUpdate struct {
+	// Actions with new scores since the last update. The first update of a subscription has none.
+	Actions []string
+	// Report of every action of the scoreType, as of the update.
+	// It is shared by subscribers asking for the same stats, so don't modify it.
+	Report StatsReport
+	// Missed is the number of updates before this one that the subscriber was too slow for.
+	Missed int
+}
+
+// SlowPolicy decides what happens to updates for a subscriber that isn't keeping up.
+type SlowPolicy int
+
+const (
+	// Drop updates that don't fit in the subscriber's buffer. The worker never waits.
+	Drop SlowPolicy = iota
+	// Coalesce updates the subscriber hasn't taken into the latest one. The worker never waits.
+	Coalesce
+	// Block the worker until the subscriber takes the update, or drop it after a timeout.
+	// Every other caller of the ScoreKeeper waits too, so keep the timeout short.
+	Block
+)
+
+// defaultBlockTimeout is how long Block waits on a subscriber by default.
+const defaultBlockTimeout = 100 * time.Millisecond
+
+// subscriber of the updates to one scoreType.
+type subscriber struct {
+	ch        chan StatsUpdate
+	scoreType string
+	stats     []string
+	policy    SlowPolicy
+	buffer    int
+	timeout   time.Duration
+	// missed updates not yet reported
+	missed int
+	// removed is set once the subscription is closed, guarded by statsSubscribers
+	removed bool
+}
+
+// SubscribeOption configures a subscription in Subscribe.
+type SubscribeOption func(sub *subscriber)
+
+// WithPolicy sets what happens to updates when the subscriber is slow. The default is Drop.
+func WithPolicy(p SlowPolicy) SubscribeOption {
+	return func(sub *subscriber) {
+		sub.policy = p
+	}
+}
+
+// WithBlockTimeout blocks for up to d on a slow subscriber, using Block.
+func WithBlockTimeout(d time.Duration) SubscribeOption {
+	return func(sub *subscriber) {
+		sub.policy = Block
+		sub.timeout = d
+	}
+}
+
+// WithBuffer sets how many updates the subscription holds for Drop and Block. The default is 16.
+// Coalesce always holds just the latest.
+func WithBuffer(n int) SubscribeOption {
+	return func(sub *subscriber) {
+		sub.buffer = n
+	}
+}
+
+// WithUpdateStats names the stats in each update, like GetStats. The default is "avg".
+func WithUpdateStats(names ...string) SubscribeOption {
+	return func(sub *subscriber) {
+		sub.stats = names
+	}
+}
+
+var ErrBadSubscription = errors.New("invalid subscription")
+
+// Subscribe returns a channel of StatsUpdates for scoreType, sent by the worker as scores are stored.
+// The first update is a report of the stats so far, if there are any.
+// The channel is closed when ctx is done or the ScoreKeeper stops.
+func (sk *ScoreKeeper) Subscribe(ctx context.Context, scoreType string, opts ...SubscribeOption) (<-chan StatsUpdate, error) {
+	sub := &subscriber{
+		scoreType: scoreType,
+		stats:     []string{"avg"},
+		buffer:    16,
+		timeout:   defaultBlockTimeout,
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+
+	switch {
+	case sub.policy == Coalesce:
+		sub.buffer = 1
+	case sub.buffer < 0:
+		return nil, fmt.Errorf("%w: negative buffer", ErrBadSubscription)
+	case sub.policy == Block && sub.timeout <= 0:
+		return nil, fmt.Errorf("%w: Block needs a timeout", ErrBadSubscription)
+	}
+
+	w, err := sk.channels()
+	if err != nil {
+		return nil, err
+	}
+
+	// the worker subscribes sub as it reports the stats so far, so no score is stored in between,
+	// and the report also starts it keeping the stats running
+	sub.ch = make(chan StatsUpdate, sub.buffer)
+	if _, err := sk.requestFor(ctx, scoreType, store.Query{}, sub.stats, sub); err != nil {
+		sk.statsSubs.remove(sub)
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.quit:
+		}
+		sk.statsSubs.remove(sub)
+	}()
+
+	return sub.ch, nil
+}
+
+// subscribe sub for the worker, given the report of the stats so far,
+// which is its first update if there are any stats to report.
+func (sk *ScoreKeeper) subscribe(sub *subscriber, first StatsReport, err error) error {
+	if err != nil && !errors.Is(err, stat.ErrNoData) {
+		return err
+	}
+
+	var u *StatsUpdate
+	if first.ScoreType != "" && sub.buffer > 0 {
+		u = &StatsUpdate{Report: first}
+	}
+	sk.statsSubs.add(sub, u)
+	return nil
+}
+
+// statsSubscribers are the subscriptions StatsUpdates are sent to.
+// The worker publishes and callers subscribe, so it is locked.
+type statsSubscribers struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+// add sub, sending it first if there is one, unless it was removed already.
+func (ss *statsSubscribers) add(sub *subscriber, first *StatsUpdate) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if sub.removed {
+		return
+	}
+	if first != nil {
+		sub.ch <- *first
+	}
+
+	if ss.subs == nil {
+		ss.subs = map[*subscriber]struct{}{}
+	}
+	ss.subs[sub] = struct{}{}
+}
+
+// remove and close sub, once nothing can publish to it. Removing it again does nothing.
+func (ss *statsSubscribers) remove(sub *subscriber) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if sub.removed {
+		return
+	}
+	sub.removed = true
+	delete(ss.subs, sub)
+	close(sub.ch)
+}
+
+// any reports whether scoreType has subscribers.
+func (ss *statsSubscribers) any(scoreType string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for sub := range ss.subs {
+		if sub.scoreType == scoreType {
+			return true
+		}
+	}
+	return false
+}
+
+// publish an update of the actions of scoreType to its subscribers, reporting the stats each asked for.
+// The report is computed once for each distinct list of stats, and shared by the subscribers that asked for it.
+func (ss *statsSubscribers) publish(scoreType string, actions []string, report func(names []string) (StatsReport, error)) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	type reported struct {
+		r   StatsReport
+		err error
+	}
+	reports := map[string]reported{}
+
+	for sub := range ss.subs {
+		if sub.scoreType != scoreType {
+			continue
+		}
+
+		key := strings.Join(sub.stats, "\x00")
+		rep, ok := reports[key]
+		if !ok {
+			rep.r, rep.err = report(sub.stats)
+			reports[key] = rep
+		}
+		if rep.err != nil {
+			continue
+		}
+
+		sub.send(StatsUpdate{Actions: actions, Report: rep.r})
+	}
+}
+
+// send u to the subscriber by its policy.
+func (sub *subscriber) send(u StatsUpdate) {
+	u.Missed = sub.missed
+
+	switch sub.policy {
+	case Coalesce:
+		// only the worker sends, so once the waiting update is taken there is room
+		select {
+		case waiting := <-sub.ch:
+			u.Actions = merge(waiting.Actions, u.Actions)
+			u.Missed += waiting.Missed + 1
+		default:
+		}
+		sub.ch <- u
+		sub.missed = 0
+
+	case Block:
+		t := time.NewTimer(sub.timeout)
+		defer t.Stop()
+
+		select {
+		case sub.ch <- u:
+			sub.missed = 0
+		case <-t.C:
+			sub.missed++
+		}
+
+	default:
+		select {
+		case sub.ch <- u:
+			sub.missed = 0
+		default:
+			sub.missed++
+		}
+	}
+}
+
+// merge the actions of b into a, without repeats.
+func merge(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, action := range b {
+		if !contains(merged, action) {
+			merged = append(merged, action)
+		}
+	}
+	return merged
+}
+
+// updated publishes a StatsUpdate for the actions of the scores just stored, leaving out outliers.
+func (sk *ScoreKeeper) updated(ss []score.Score) {
+	byType := map[string][]string{}
+	var types []string
+	for _, s := range ss {
+		if score.IsOutlier(s) {
+			continue
+		}
+		scoreType := s.Type()
+		if _, ok := byType[scoreType]; !ok {
+			types = append(types, scoreType)
+		}
+		if !contains(byType[scoreType], s.Name()) {
+			byType[scoreType] = append(byType[scoreType], s.Name())
+		}
+	}
+
+	for _, scoreType := range types {
+		if !sk.statsSubs.any(scoreType) {
+			continue
+		}
+
+		sk.statsSubs.publish(scoreType, byType[scoreType], func(names []string) (StatsReport, error) {
+			return sk.aggs.report(scoreType, names)
+		})
+	}
+}
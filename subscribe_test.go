@@ -0,0 +1,331 @@
+package scorekeeper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bdharris08/scorekeeper/score"
+	"github.com/bdharris08/scorekeeper/stat"
+	"github.com/bdharris08/scorekeeper/store"
+)
+
+func newStartedKeeper(t *testing.T) *ScoreKeeper {
+	t.Helper()
+
+	factory := score.ScoreFactory{
+		"trial": func() score.Score { return &score.Trial{} },
+	}
+
+	s, err := New(&store.MemoryStore{}, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+
+	return s
+}
+
+// next update from updates, failing if there isn't one.
+func next(t *testing.T, updates <-chan StatsUpdate) StatsUpdate {
+	t.Helper()
+
+	select {
+	case u, ok := <-updates:
+		if !ok {
+			t.Fatal("Expected an update but the subscription was closed")
+		}
+		return u
+	case <-time.After(time.Second):
+		t.Fatal("Expected an update")
+	}
+	return StatsUpdate{}
+}
+
+// none fails if there is an update waiting in updates.
+func none(t *testing.T, updates <-chan StatsUpdate) {
+	t.Helper()
+
+	select {
+	case u := <-updates:
+		t.Errorf("Expected no update but got %+v", u)
+	default:
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := newStartedKeeper(t)
+
+	if err := s.AddAction("trial", `{"action":"hop", "time":100}`); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := s.Subscribe(ctx, "trial", WithUpdateStats("avg", "count"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first update is the stats so far
+	u := next(t, updates)
+	if len(u.Actions) != 0 {
+		t.Errorf("Expected no actions in the first update but got %v", u.Actions)
+	}
+	if res, err := u.Report.JSON(); err != nil || res != `[{"action":"hop","avg":100,"count":1}]` {
+		t.Errorf("Expected the stats so far but got '%s' '%v'", res, err)
+	}
+
+	if err := s.AddAction("trial", `{"action":"jump", "time":50}`); err != nil {
+		t.Fatal(err)
+	}
+	u = next(t, updates)
+	if expected, got := []string{"jump"}, u.Actions; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected actions %v but got %v", expected, got)
+	}
+	if res, err := u.Report.JSON(); err != nil || res != `[{"action":"hop","avg":100,"count":1},{"action":"jump","avg":50,"count":1}]` {
+		t.Errorf("Expected the updated stats but got '%s' '%v'", res, err)
+	}
+
+	// a batch is one update
+	if _, err := s.AddActions("trial", []string{`{"action":"jump", "time":150}`, `{"action":"hop", "time":200}`}); err != nil {
+		t.Fatal(err)
+	}
+	u = next(t, updates)
+	if expected, got := []string{"jump", "hop"}, u.Actions; !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected actions %v but got %v", expected, got)
+	}
+	none(t, updates)
+
+	cancel()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Error("Expected no more updates")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the subscription to be closed once ctx was done")
+	}
+
+	if _, err := s.Subscribe(context.Background(), "trial", WithUpdateStats("mode")); !errors.Is(err, stat.ErrUnknownStat) {
+		t.Errorf("Expected error to be '%v' but got '%v'", stat.ErrUnknownStat, err)
+	}
+	if _, err := s.Subscribe(context.Background(), "race"); err != score.ErrBadScoreType {
+		t.Errorf("Expected error to be '%v' but got '%v'", score.ErrBadScoreType, err)
+	}
+	if _, err := s.Subscribe(context.Background(), "trial", WithBlockTimeout(0)); !errors.Is(err, ErrBadSubscription) {
+		t.Errorf("Expected error to be '%v' but got '%v'", ErrBadSubscription, err)
+	}
+}
+
+func TestSubscribePolicies(t *testing.T) {
+	actions := []string{
+		`{"action":"hop", "time":100}`,
+		`{"action":"jump", "time":50}`,
+		`{"action":"hop", "time":200}`,
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		s := newStartedKeeper(t)
+		updates, err := s.Subscribe(context.Background(), "trial", WithBuffer(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, a := range actions {
+			if err := s.AddAction("trial", a); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the first update fit, the rest were dropped
+		u := next(t, updates)
+		if expected, got := []string{"hop"}, u.Actions; !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expected actions %v but got %v", expected, got)
+		}
+		if expected, got := 0, u.Missed; expected != got {
+			t.Errorf("Expected %d missed but got %d", expected, got)
+		}
+		none(t, updates)
+
+		if err := s.AddAction("trial", `{"action":"skip", "time":10}`); err != nil {
+			t.Fatal(err)
+		}
+		u = next(t, updates)
+		if expected, got := 2, u.Missed; expected != got {
+			t.Errorf("Expected %d missed but got %d", expected, got)
+		}
+		if expected, got := 3, len(u.Report.Actions); expected != got {
+			t.Errorf("Expected a report of %d actions but got %d", expected, got)
+		}
+	})
+
+	t.Run("coalesce", func(t *testing.T) {
+		s := newStartedKeeper(t)
+		updates, err := s.Subscribe(context.Background(), "trial", WithPolicy(Coalesce))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, a := range actions {
+			if err := s.AddAction("trial", a); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// one update, as of the latest score
+		u := next(t, updates)
+		if expected, got := []string{"hop", "jump"}, u.Actions; !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expected actions %v but got %v", expected, got)
+		}
+		if expected, got := 2, u.Missed; expected != got {
+			t.Errorf("Expected %d missed but got %d", expected, got)
+		}
+		if res, err := u.Report.JSON(); err != nil || res != `[{"action":"hop","avg":150},{"action":"jump","avg":50}]` {
+			t.Errorf("Expected the latest stats but got '%s' '%v'", res, err)
+		}
+		none(t, updates)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		s := newStartedKeeper(t)
+		updates, err := s.Subscribe(context.Background(), "trial", WithBlockTimeout(time.Minute), WithBuffer(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(chan StatsUpdate, len(actions))
+		go func() {
+			for u := range updates {
+				got <- u
+			}
+			close(got)
+		}()
+
+		for _, a := range actions {
+			if err := s.AddAction("trial", a); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the worker waited for every update to be taken
+		for i := range actions {
+			u := <-got
+			if expected, got := 0, u.Missed; expected != got {
+				t.Errorf("[%d] Expected %d missed but got %d", i, expected, got)
+			}
+		}
+	})
+
+	t.Run("block timeout", func(t *testing.T) {
+		s := newStartedKeeper(t)
+		timeout := 20 * time.Millisecond
+		updates, err := s.Subscribe(context.Background(), "trial", WithBlockTimeout(timeout), WithBuffer(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		if err := s.AddAction("trial", actions[0]); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < timeout {
+			t.Errorf("Expected the worker to wait %v for the subscriber but it took %v", timeout, elapsed)
+		}
+
+		got := make(chan StatsUpdate, 1)
+		go func() {
+			got <- <-updates
+		}()
+		if err := s.AddAction("trial", actions[1]); err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := 1, (<-got).Missed; expected != got {
+			t.Errorf("Expected %d missed but got %d", expected, got)
+		}
+	})
+}
+
+func TestSubscribeWhileAdding(t *testing.T) {
+	s := newStartedKeeper(t)
+	adders, n := 8, 100
+
+	var wg sync.WaitGroup
+	halfway := make(chan struct{}, adders)
+	for a := 0; a < adders; a++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				if i == n/2 {
+					halfway <- struct{}{}
+				}
+				if err := s.AddAction("trial", `{"action":"jump", "time":100}`); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	<-halfway
+	updates, err := s.Subscribe(context.Background(), "trial", WithUpdateStats("count"), WithBuffer(adders*n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	// every score is in the first report or an update after it
+	var seen int
+	for {
+		select {
+		case u := <-updates:
+			if len(u.Actions) == 0 {
+				seen += u.Report.Actions[0].Count
+			} else {
+				seen++
+			}
+			continue
+		default:
+		}
+		break
+	}
+	if expected, got := adders*n, seen; expected != got {
+		t.Errorf("Expected %d scores in the first report and updates but got %d", expected, got)
+	}
+}
+
+func TestPublishSharesReports(t *testing.T) {
+	var ss statsSubscribers
+	subs := []*subscriber{
+		{ch: make(chan StatsUpdate, 1), scoreType: "trial", stats: []string{"avg"}},
+		{ch: make(chan StatsUpdate, 1), scoreType: "trial", stats: []string{"avg"}},
+		{ch: make(chan StatsUpdate, 1), scoreType: "trial", stats: []string{"avg", "p99"}},
+		{ch: make(chan StatsUpdate, 1), scoreType: "race", stats: []string{"avg"}},
+	}
+	for _, sub := range subs {
+		ss.add(sub, nil)
+	}
+
+	var reports []string
+	ss.publish("trial", []string{"hop"}, func(names []string) (StatsReport, error) {
+		reports = append(reports, strings.Join(names, ","))
+		return StatsReport{ScoreType: "trial"}, nil
+	})
+
+	// one report for each distinct list of stats asked for, however many subscribers asked
+	if expected, got := 2, len(reports); expected != got {
+		t.Errorf("Expected %d reports but got %d: %v", expected, got, reports)
+	}
+	for i, sub := range subs {
+		if expected, got := sub.scoreType == "trial", len(sub.ch) == 1; expected != got {
+			t.Errorf("[%d] Expected an update to be sent: %t", i, expected)
+		}
+	}
+}